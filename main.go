@@ -1,36 +1,72 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
 	"strings"
-	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gookit/color"
 	"github.com/kevincobain2000/email_extractor/pkg"
+	"github.com/sirupsen/logrus"
 )
 
 var version = "dev"
 
 type Flags struct {
 	version       bool
+	debug         bool
+	verify        bool
 	ignoreQueries bool
 	parallel      bool
+	ignoreRobots  bool
+	sitemap       bool
+	sitemapOnly   bool
 	url           string
 	urlFile       string
 	writeToFile   string
+	format        string
+	db            string
+	deobfuscate   string
+	extract       string
+	resolver      string
+	exclude       string
+	classLists    string
+	documents     string
+	contentTypes  string
+	stateDir      string
+	resume        string
 	limitUrls     int
 	limitEmails   int
 	maxWorkers    int
+	verifyWorkers int
+	snippetWindow int
+	retries       int
+	hostInFlight  int
+	hostRate      float64
 	depth         int
 	timeout       int64
+	maxDocument   int64
+	maxBody       int64
+	backoff       int64
 	sleep         int64
+	maxDuration   time.Duration
 }
 
 var f Flags
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		serve(os.Args[2:])
+		return
+	}
+
 	SetupFlags()
 	startTime := time.Now()
 
@@ -38,67 +74,243 @@ func main() {
 		fmt.Println(version)
 		return
 	}
+	if f.debug {
+		pkg.Logger().SetLevel(logrus.DebugLevel)
+	}
+
+	if !pkg.StringInSlice(f.format, pkg.Formats) {
+		color.Danger.Println(fmt.Sprintf("Unknown format %q, use one of %s", f.format, strings.Join(pkg.Formats, ", ")))
+		return
+	}
+
+	deobfuscate := splitList(f.deobfuscate)
+	extract := splitList(f.extract)
+	if _, err := pkg.NewExtractors(extract, &pkg.CrawlOptions{Deobfuscate: deobfuscate}); err != nil {
+		color.Danger.Println(err)
+		return
+	}
+	documents := splitList(f.documents)
+	if _, err := pkg.ParseDocumentFormats(documents); err != nil {
+		color.Danger.Println(err)
+		return
+	}
+	exclude := splitList(f.exclude)
+	if err := pkg.ParseClasses(exclude); err != nil {
+		color.Danger.Println(err)
+		return
+	}
+	if _, err := pkg.NewClassifier(f.classLists); err != nil {
+		color.Danger.Println(err)
+		return
+	}
+
+	// The requests in flight are cancelled after -max-duration or on a second
+	// SIGINT/SIGTERM, see below, and the summary is still printed
+	ctx, cancel := context.WithCancel(context.Background())
+	if f.maxDuration > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), f.maxDuration)
+	}
+	defer cancel()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	var state *pkg.CrawlState
+	if f.stateDir != "" {
+		var err error
+		state, err = openState()
+		if err != nil {
+			color.Danger.Println("Error opening crawl state:", err)
+			return
+		}
+		defer state.Close()
+	}
 
 	options := []pkg.CrawlOption{
 		func(opt *pkg.CrawlOptions) error {
 			opt.TimeoutMillisecond = f.timeout
 			opt.SleepMillisecond = f.sleep
+			opt.Retries = f.retries
+			opt.BackoffMillisecond = f.backoff
 			opt.LimitUrls = f.limitUrls
 			opt.LimitEmails = f.limitEmails
 			opt.WriteToFile = f.writeToFile
+			opt.Format = f.format
 			opt.URL = f.url
 			opt.Depth = f.depth
 			opt.IgnoreQueries = f.ignoreQueries
 			opt.CrawlFromFile = f.urlFile != ""
 			opt.MaxWorkers = f.maxWorkers
+			opt.IgnoreRobots = f.ignoreRobots
+			opt.HostRate = f.hostRate
+			opt.HostMaxInFlight = f.hostInFlight
+			opt.Deobfuscate = deobfuscate
+			opt.Extract = extract
+			opt.SnippetWindow = f.snippetWindow
+			opt.Verify = f.verify
+			opt.Resolver = f.resolver
+			opt.VerifyWorkers = f.verifyWorkers
+			opt.Exclude = exclude
+			opt.ClassLists = f.classLists
+			opt.Documents = documents
+			opt.MaxDocumentBytes = f.maxDocument
+			opt.MaxBodyBytes = f.maxBody
+			opt.ContentTypes = splitList(f.contentTypes)
 			return nil
 		},
 	}
 
-	hc := pkg.NewHTTPChallenge(options...)
-	
-	// Check if we should crawl from file or single URL
-	if f.urlFile != "" {
-		// Crawl from file containing URLs
-		urls, err := pkg.ReadURLsFromFile(f.urlFile)
+	hc, err := pkg.NewHTTPChallenge(options...)
+	if err != nil {
+		color.Danger.Println(err)
+		return
+	}
+	if state != nil {
+		hc.UseState(state)
+	}
+
+	// Stop dispatching new URLs on SIGINT/SIGTERM, URLs being crawled are
+	// finished or time out. A second signal cancels them, a third one kills
+	// the process
+	go func() {
+		select {
+		case <-signals:
+			hc.Stop()
+		case <-ctx.Done():
+			return
+		}
+		select {
+		case <-signals:
+			cancel()
+			signal.Stop(signals)
+		case <-ctx.Done():
+		}
+	}()
+
+	var db *pkg.EmailDB
+	if f.db != "" {
+		var err error
+		run := f.url
+		if f.urlFile != "" {
+			run = f.urlFile
+		}
+		db, err = pkg.OpenEmailDB(f.db, run)
 		if err != nil {
-			color.Danger.Println("Error reading URLs from file:", err)
+			color.Danger.Println("Error opening database:", err)
 			return
 		}
-		
+		hc.AddSink(db)
+	}
+
+	resumed := f.resume != "" && state.Resumable()
+
+	// Check if we should crawl from file or single URL
+	if f.urlFile != "" || f.sitemapOnly {
+		var urls []string
+		if f.urlFile != "" {
+			// Crawl from file containing URLs
+			var err error
+			urls, err = pkg.ReadURLsFromFile(f.urlFile)
+			if err != nil {
+				color.Danger.Println("Error reading URLs from file:", err)
+				return
+			}
+		} else {
+			// Crawl only the URL and the URLs listed in its sitemaps
+			urls = append([]string{f.url}, hc.SitemapURLs(ctx, f.url)...)
+		}
+
 		if f.parallel {
-			hc.CrawlURLsWithWorkerPool(urls)
+			hc.CrawlURLsWithWorkerPool(ctx, urls)
 		} else {
 			for _, url := range urls {
+				if ctx.Err() != nil || hc.LimitReached() {
+					break
+				}
 				if hc.HasURL(url) {
 					continue
 				}
 				hc.AddURL(url)
-				hc.CrawlSingleURL(url)
+				hc.CrawlSingleURL(ctx, url)
 			}
 		}
 	} else {
 		// Original behavior - crawl recursively from single URL with limits
+		// Sitemaps seed the URLs that links do not lead to
+		var seeds []string
+		if f.sitemap && f.depth != 0 && !resumed {
+			seeds = hc.SitemapURLs(ctx, f.url)
+		}
+		urls := append([]string{f.url}, seeds...)
+		if resumed {
+			urls = hc.PendingURLs()
+		}
 		if f.parallel {
-			var wgC sync.WaitGroup
-			wgC.Add(1)
-			hc.CrawlRecursiveParallel(f.url, &wgC)
-			wgC.Wait()
+			hc.CrawlRecursiveParallelURLs(ctx, urls)
 		} else {
-			hc.CrawlRecursive(f.url)
+			hc.CrawlRecursiveURLs(ctx, urls)
 		}
 	}
 
 	fmt.Println()
+	var newEmails []string
+	if db != nil {
+		var err error
+		newEmails, err = db.NewEmails()
+		if err != nil {
+			color.Danger.Println("Error reading database:", err)
+		}
+	}
+	if err := hc.Close(); err != nil {
+		color.Danger.Print("File write")
+		color.Secondary.Print("....................")
+		color.Danger.Println("Error writing emails to file:", err)
+	}
+
 	color.Secondary.Println("-------------------------------------")
 	color.Warn.Print("Crawling")
 	color.Secondary.Print("....................")
-	color.Success.Println("Complete!")
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		color.Warn.Println("Stopped (max duration reached)")
+	case hc.Stopped():
+		color.Warn.Println("Stopped (interrupted)")
+	default:
+		color.Success.Println("Complete!")
+	}
 	color.Warn.Print("URLs")
 	color.Secondary.Print("........................")
 	ratio := (float64(hc.TotalURLsFound) / float64(hc.TotalURLsCrawled)) * 100
 	fmt.Printf("%d urls crawled, %d urls with emails (%.2f﹪ hit rate)\n", hc.TotalURLsCrawled, hc.TotalURLsFound, ratio)
 
+	if len(hc.SkippedURLs) > 0 {
+		color.Warn.Print("Skipped")
+		color.Secondary.Print(".....................")
+		fmt.Printf("%d urls skipped\n", len(hc.SkippedURLs))
+		countPerReason := map[string]int{}
+		for _, s := range hc.SkippedURLs {
+			countPerReason[s.Reason]++
+		}
+		for reason, count := range countPerReason {
+			color.Secondary.Print("                            ")
+			fmt.Printf("(%d) %s\n", count, reason)
+		}
+	}
+
+	if len(hc.FailedURLs) > 0 {
+		color.Danger.Print("Failed")
+		color.Secondary.Print("......................")
+		fmt.Printf("%d urls failed\n", len(hc.FailedURLs))
+		failed := append([]pkg.FailedURL{}, hc.FailedURLs...)
+		sort.SliceStable(failed, func(i, j int) bool {
+			return failed[i].Category < failed[j].Category
+		})
+		for _, u := range failed {
+			color.Secondary.Print("                            ")
+			fmt.Printf("%s %s (%s)\n", u.Category, u.URL, u.Error)
+		}
+	}
+
 	hc.Emails = pkg.UniqueStrings(hc.Emails)
 
 	color.Warn.Print("Unique emails")
@@ -122,14 +334,62 @@ func main() {
 		}
 	}
 
+	if len(hc.Emails) > 0 || len(exclude) > 0 {
+		counts := hc.ClassCounts()
+		color.Warn.Print("Email classes")
+		color.Secondary.Print("...............")
+		results := []string{}
+		for _, class := range pkg.Classes {
+			result := fmt.Sprintf("%d %s", counts[class], class)
+			if pkg.StringInSlice(class, exclude) {
+				result += " (excluded)"
+			}
+			results = append(results, result)
+		}
+		fmt.Println(strings.Join(results, ", "))
+	}
+
+	if counts := hc.MXCounts(); counts != nil {
+		color.Warn.Print("MX verified")
+		color.Secondary.Print(".................")
+		results := []string{}
+		for _, status := range pkg.MXStatuses {
+			results = append(results, fmt.Sprintf("%d %s", counts[status], status))
+		}
+		fmt.Println(strings.Join(results, ", "))
+	}
+
+	for _, t := range []string{pkg.RecordPhone, pkg.RecordSocial, pkg.RecordContactForm} {
+		if len(hc.Findings[t]) == 0 {
+			continue
+		}
+		label := "Unique " + strings.ReplaceAll(t, "_", " ") + "s"
+		color.Warn.Print(label)
+		color.Secondary.Print(strings.Repeat(".", 28-len(label)))
+		fmt.Printf("%d found\n", len(hc.Findings[t]))
+	}
+
+	if db != nil {
+		color.Warn.Print("New emails")
+		color.Secondary.Print("..................")
+		fmt.Printf("%d addresses not found by previous runs\n", len(newEmails))
+		color.Warn.Print("Database")
+		color.Secondary.Print("....................")
+		color.Note.Println(f.db)
+	}
+
 	if f.writeToFile != "" {
-		// Emails are already saved real-time, just show the file path
+		// Emails are already saved, just show the file path
 		color.Warn.Print("Output file")
 		color.Secondary.Print(".................")
 		color.Note.Println(f.writeToFile)
 		color.Secondary.Print("Note")
 		color.Secondary.Print("....................")
-		color.Success.Println("Emails saved real-time during crawling")
+		if f.format == pkg.FormatJSON {
+			color.Success.Println("Emails saved once crawling finished")
+		} else {
+			color.Success.Println("Emails saved real-time during crawling")
+		}
 	}
 	endTime := time.Now()
 	color.Warn.Print("Time taken")
@@ -139,32 +399,184 @@ func main() {
 	fmt.Println(formattedDuration)
 }
 
+// serve runs the HTTP API to start, follow and cancel crawl jobs, until
+// SIGINT/SIGTERM.
+func serve(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:8080", "address to listen on")
+	if err := fs.Parse(args); err != nil {
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	jobs := pkg.NewJobs()
+	e := pkg.NewServer(jobs)
+	go func() {
+		<-ctx.Done()
+		// jobs finish the urls being crawled, a second signal kills the
+		// server
+		stop()
+		jobs.CancelAll()
+		if err := e.Shutdown(context.Background()); err != nil {
+			color.Danger.Println("Error stopping server:", err)
+		}
+	}()
+
+	color.Warn.Print("Serving")
+	color.Secondary.Print(".....................")
+	color.Note.Println("http://" + *addr)
+	if err := e.Start(*addr); err != nil && err != http.ErrServerClosed {
+		color.Danger.Println("Error starting server:", err)
+	}
+}
+
+// openState opens the state dir, and when resuming, takes the url or url
+// file of the interrupted crawl unless given again.
+func openState() (*pkg.CrawlState, error) {
+	state, err := pkg.OpenCrawlState(f.stateDir)
+	if err != nil {
+		return nil, err
+	}
+	if f.resume == "" {
+		if state.Resumable() {
+			state.Close()
+			return nil, fmt.Errorf("%s holds a previous crawl, use -resume=%s to continue it", f.stateDir, f.stateDir)
+		}
+		return state, state.Start(f.url, f.urlFile)
+	}
+
+	snapshot := state.Snapshot()
+	if f.url == "" && f.urlFile == "" {
+		f.url = snapshot.URL
+		f.urlFile = snapshot.URLFile
+	}
+	if !state.Resumable() {
+		return state, state.Start(f.url, f.urlFile)
+	}
+	color.Warn.Print("Resuming")
+	color.Secondary.Print("....................")
+	fmt.Printf("%d urls done, %d pending, %d emails\n", len(snapshot.Done), len(snapshot.Pending), len(snapshot.Emails))
+	return state, nil
+}
+
+// splitList splits a comma separated flag value.
+func splitList(value string) []string {
+	list := []string{}
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
 func SetupFlags() {
+	d := pkg.DefaultCrawlOptions()
 	flag.StringVar(&f.url, "url", "", "url to crawl")
 	flag.StringVar(&f.urlFile, "f", "", "file containing URLs to crawl (one URL per line)")
-	flag.StringVar(&f.writeToFile, "out", "emails.txt", "file to write to")
+	flag.StringVar(&f.writeToFile, "out", "", "file to write to (default \"emails.<format>\")")
+	flag.StringVar(&f.format, "format", d.Format, `format of the output file
+txt   one email per line
+jsonl one record per line, with the source url, page title, status, time, depth, method and its
+      confidence, the name, nearest heading and snippet of text around it, and the job title
+      and organization of structured data
+csv   the same records as csv
+json  the same records as a json array, written once the crawl is done`)
+	flag.StringVar(&f.db, "db", "", "sqlite database to also save emails to, updated across runs")
+	flag.StringVar(&f.stateDir, "state", "", "directory to checkpoint crawl state to, so the crawl can be resumed")
+	flag.StringVar(&f.resume, "resume", "", "state directory of an interrupted crawl to resume")
 
-	flag.IntVar(&f.limitUrls, "limit-urls", 1000, "limit of urls to crawl")
-	flag.IntVar(&f.limitEmails, "limit-emails", 1000, "limit of emails to crawl")
-	flag.IntVar(&f.maxWorkers, "max-workers", 50, "maximum number of concurrent workers when crawling from file")
+	flag.IntVar(&f.limitUrls, "limit-urls", d.LimitUrls, "limit of urls to crawl")
+	flag.IntVar(&f.limitEmails, "limit-emails", d.LimitEmails, "limit of emails to crawl")
+	flag.IntVar(&f.maxWorkers, "max-workers", d.MaxWorkers, "maximum number of concurrent workers")
 
-	flag.IntVar(&f.depth, "depth", -1, `depth of urls to crawl.
+	flag.IntVar(&f.depth, "depth", d.Depth, `depth of urls to crawl.
 -1 for url provided & all depths (both backward and forward)
 0  for url provided (only this)
 1  for url provided & until first level (forward)
 2  for url provided & until second level (forward)`)
 
-	flag.Int64Var(&f.timeout, "timeout", 10000, "timeout limit in milliseconds for each request")
-	flag.Int64Var(&f.sleep, "sleep", 0, "sleep in milliseconds before each request to avoid getting blocked")
+	flag.Int64Var(&f.timeout, "timeout", d.TimeoutMillisecond, "timeout limit in milliseconds for each request")
+	flag.Int64Var(&f.sleep, "sleep", d.SleepMillisecond, "minimum milliseconds between requests to the same host to avoid getting blocked")
+	flag.IntVar(&f.retries, "retries", d.Retries, `times to retry a url failing with a timeout, a refused or dropped connection, a 5xx or a 429
+dns failures other than timeouts and tls errors are not retried, urls still failing are listed at the end`)
+	flag.Int64Var(&f.backoff, "retry-backoff", d.BackoffMillisecond, "milliseconds before the first retry, doubled for each next one, with jitter, or as long as Retry-After asks")
+	flag.Float64Var(&f.hostRate, "host-rps", d.HostRate, "maximum requests per second to the same host, 0 for no limit")
+	flag.IntVar(&f.hostInFlight, "host-max-inflight", d.HostMaxInFlight, "maximum concurrent requests to the same host, 0 for no limit")
+
+	flag.StringVar(&f.extract, "extract", strings.Join(d.Extract, ","), `comma separated types of records to extract
+emails      email addresses
+phones      phone numbers of tel links, and international ones in the text
+socials     links to social profiles
+contacts    urls of contact forms
+structured  emails, and phones when extracting phones, of schema.org json-ld, microdata and
+            h-cards, with the names, job titles and organizations they are published with`)
+	flag.IntVar(&f.snippetWindow, "snippet-window", d.SnippetWindow, "characters of text each side of a finding to save as its snippet, 0 for none")
+	flag.StringVar(&f.deobfuscate, "deobfuscate", strings.Join(d.Deobfuscate, ","), `comma separated decoders of hidden emails, empty for none
+cloudflare  Cloudflare email protection (data-cfemail, /cdn-cgi/l/email-protection#)
+entities    html entities, like john&#64;example.com
+urlencoded  url encoding, like john%40example.com
+textual     name AT domain DOT com, name [at] domain (dot) com, name @ domain . com`)
+
+	flag.BoolVar(&f.verify, "verify", false, "verify the domains of emails accept mail, from their MX or else A/AAAA records, as mx_ok, no_mx or nxdomain")
+	flag.StringVar(&f.resolver, "resolver", "", "DNS server to verify with, as host:port (default the first nameserver of /etc/resolv.conf)")
+	flag.IntVar(&f.verifyWorkers, "verify-workers", d.VerifyWorkers, "maximum concurrent DNS lookups to verify with")
+
+	flag.StringVar(&f.exclude, "exclude", "", `comma separated classes of emails to leave out of the output
+personal     any address of no other class
+role         info@, sales@, support@, admin@ and the like
+noreply      noreply@, donotreply@, mailer-daemon@ and the like
+placeholder  examples of templates and docs, like you@yourdomain.com
+disposable   addresses of throwaway providers, like mailinator.com`)
+	flag.StringVar(&f.classLists, "class-lists", "", `directory of role.txt, noreply.txt, placeholder.txt and disposable.txt, adding patterns to the embedded ones
+one per line: local@, local*@ (a prefix), @domain or local@domain`)
+
+	flag.StringVar(&f.documents, "documents", "", `comma separated formats of linked documents to also extract from, empty for none
+pdf     text, annotations and links of pdfs
+office  text, cells, hyperlinks and properties, like the author, of docx, xlsx and pptx files
+odf     text, cells, links and metadata of OpenDocument odt, ods and odp files`)
+	flag.Int64Var(&f.maxDocument, "max-document-bytes", d.MaxDocumentBytes, "size of the largest document to download, larger ones are skipped")
+	flag.Int64Var(&f.maxBody, "max-body-bytes", d.MaxBodyBytes, "size of the largest page, html or other content, to download, larger ones are skipped")
+	flag.StringVar(&f.contentTypes, "content-types", strings.Join(d.ContentTypes, ","), `comma separated media types of the pages to crawl, type/* for all of a type
+html pages are crawled for links, the others only read:
+text/plain                 as text
+application/json           the strings of json, and its schema.org things
+application/xml            the text of xml, and the authors of rss and atom feeds
+text/vcard                 the people of vcards, with their names, titles and organizations
+text/calendar              the organizers and attendees of icalendar events
+vcf, ics, json and xml files served as text/plain are read as such`)
+
+	flag.DurationVar(&f.maxDuration, "max-duration", 0, "stop crawling after this long, e.g. 30m (0 for no limit)")
 
 	flag.BoolVar(&f.version, "version", false, "prints version")
-	flag.BoolVar(&f.ignoreQueries, "ignore-queries", true, `ignore query params in the url
+	flag.BoolVar(&f.debug, "debug", false, "log debug output, like the rejected email candidates and why")
+	flag.BoolVar(&f.ignoreQueries, "ignore-queries", d.IgnoreQueries, `ignore query params in the url
 Note: pagination links are usually query params
 Set it to false, if you want to crawl such links
 `)
 	flag.BoolVar(&f.parallel, "parallel", true, "crawl urls in parallel")
+	flag.BoolVar(&f.sitemap, "sitemap", true, "also crawl the urls listed in robots.txt sitemaps and /sitemap.xml")
+	flag.BoolVar(&f.sitemapOnly, "sitemap-only", false, "crawl only the url and the urls listed in its sitemaps, without following links")
+	flag.BoolVar(&f.ignoreRobots, "ignore-robots", d.IgnoreRobots, "ignore robots.txt rules and Crawl-delay (only for sites you own)")
 	flag.Parse()
 
+	outSet := false
+	flag.Visit(func(fl *flag.Flag) {
+		outSet = outSet || fl.Name == "out"
+	})
+	if !outSet {
+		f.writeToFile = "emails." + f.format
+	}
+
+	if f.resume != "" {
+		f.stateDir = f.resume
+		if f.url == "" && f.urlFile == "" {
+			// taken from the state dir
+			return
+		}
+	}
 	if f.urlFile == "" && !strings.HasPrefix(f.url, "http") {
 		f.url = "https://" + f.url
 	}
@@ -0,0 +1,157 @@
+package pkg
+
+import (
+	"bufio"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Classes of email addresses.
+const (
+	ClassPersonal    = "personal"
+	ClassRole        = "role"
+	ClassNoReply     = "noreply"
+	ClassPlaceholder = "placeholder"
+	ClassDisposable  = "disposable"
+)
+
+// Classes are the classes of addresses, personal being any address of no
+// other class.
+var Classes = []string{ClassPersonal, ClassRole, ClassNoReply, ClassPlaceholder, ClassDisposable}
+
+// classPrecedence is the order classes are checked in, so
+// noreply@example.com is a placeholder.
+var classPrecedence = []string{ClassPlaceholder, ClassDisposable, ClassNoReply, ClassRole}
+
+//go:embed classes/*.txt
+var classLists embed.FS
+
+// emailPattern matches a local part, or a prefix of it, and a domain and its
+// subdomains, either being empty for any.
+type emailPattern struct {
+	local  string
+	prefix bool
+	domain string
+}
+
+func parseEmailPattern(line string) (emailPattern, error) {
+	local, domain, ok := strings.Cut(line, "@")
+	if !ok || (local == "" && domain == "") {
+		return emailPattern{}, fmt.Errorf("invalid pattern %q, use local@, local*@, @domain or local@domain", line)
+	}
+	p := emailPattern{domain: strings.ToLower(domain)}
+	if strings.HasSuffix(local, "*") {
+		p.prefix = true
+		local = strings.TrimSuffix(local, "*")
+	}
+	p.local = normalizeLocalPart(local)
+	return p, nil
+}
+
+func (p emailPattern) match(local, domain string) bool {
+	if p.domain != "" && domain != p.domain && !strings.HasSuffix(domain, "."+p.domain) {
+		return false
+	}
+	if p.prefix {
+		return strings.HasPrefix(local, p.local)
+	}
+	return p.local == "" || local == p.local
+}
+
+// normalizeLocalPart lowercases local, and removes its +tag and the dots,
+// hyphens and underscores, so no-reply, no_reply and noreply+123 are the
+// same.
+func normalizeLocalPart(local string) string {
+	local, _, _ = strings.Cut(strings.ToLower(local), "+")
+	return strings.NewReplacer(".", "", "-", "", "_", "").Replace(local)
+}
+
+// Classifier classifies addresses as personal, role, noreply, placeholder
+// or disposable, by the embedded lists of patterns and any extra ones.
+type Classifier struct {
+	patterns map[string][]emailPattern
+}
+
+// NewClassifier returns a classifier of the embedded lists, extended by the
+// role.txt, noreply.txt, placeholder.txt and disposable.txt files of dir, if
+// not empty.
+func NewClassifier(dir string) (*Classifier, error) {
+	c := &Classifier{patterns: map[string][]emailPattern{}}
+	for _, class := range classPrecedence {
+		if err := c.load(classLists, "classes/"+class+".txt", class); err != nil {
+			return nil, err
+		}
+		if dir == "" {
+			continue
+		}
+		err := c.load(os.DirFS(dir), class+".txt", class)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (c *Classifier) load(fsys fs.FS, name, class string) error {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	patterns, err := readEmailPatterns(file)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", filepath.Base(name), err)
+	}
+	c.patterns[class] = append(c.patterns[class], patterns...)
+	return nil
+}
+
+func readEmailPatterns(r io.Reader) ([]emailPattern, error) {
+	patterns := []emailPattern{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p, err := parseEmailPattern(line)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, scanner.Err()
+}
+
+// Classify returns the class of email, the first it matches a pattern of.
+func (c *Classifier) Classify(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ClassPersonal
+	}
+	local, domain := normalizeLocalPart(email[:at]), strings.ToLower(email[at+1:])
+	for _, class := range classPrecedence {
+		for _, p := range c.patterns[class] {
+			if p.match(local, domain) {
+				return class
+			}
+		}
+	}
+	return ClassPersonal
+}
+
+// ParseClasses checks names are classes.
+func ParseClasses(names []string) error {
+	for _, name := range names {
+		if !StringInSlice(name, Classes) {
+			return fmt.Errorf("unknown class %q, use some of %v", name, Classes)
+		}
+	}
+	return nil
+}
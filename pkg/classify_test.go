@@ -0,0 +1,84 @@
+package pkg
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "role.txt"), []byte("# ours\nops@\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "disposable.txt"), []byte("@throwaway.test\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClassifier(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		email    string
+		expected string
+	}{
+		{"jane.roe@company.com", ClassPersonal},
+		{"info@company.com", ClassRole},
+		{"Sales+EU@company.com", ClassRole},
+		{"customer-service@company.com", ClassRole},
+		{"no-reply@company.com", ClassNoReply},
+		{"noreply-12345@github.com", ClassNoReply},
+		{"do_not_reply@company.com", ClassNoReply},
+		{"MAILER-DAEMON@company.com", ClassNoReply},
+		{"example@example.com", ClassPlaceholder},
+		{"you@yourdomain.com", ClassPlaceholder},
+		{"john.doe@company.com", ClassPlaceholder},
+		{"info@mail.example.org", ClassPlaceholder},
+		{"jane@mailinator.com", ClassDisposable},
+		{"jane@throwaway.test", ClassDisposable},
+		{"ops@company.com", ClassRole},
+	}
+	for _, test := range tests {
+		t.Run(test.email, func(t *testing.T) {
+			if got := c.Classify(test.email); got != test.expected {
+				t.Errorf("Classify(%q) = %q, want %q", test.email, got, test.expected)
+			}
+		})
+	}
+}
+
+func TestNewClassifierInvalidPattern(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "role.txt"), []byte("ops\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewClassifier(dir); err == nil {
+		t.Error("NewClassifier() = nil error for a pattern without @")
+	}
+	if err := ParseClasses([]string{"role", "spam"}); err == nil {
+		t.Error("ParseClasses() = nil error for spam")
+	}
+}
+
+func TestCrawlExclude(t *testing.T) {
+	ts := newTestSite(t)
+
+	// the emails of the test site are all @example.com
+	hc := newTestHTTPChallenge(ts, "", false)
+	hc.options.Exclude = []string{ClassPlaceholder}
+	records := &recordSink{}
+	hc.AddSink(records)
+	hc.CrawlRecursiveParallel(context.Background(), ts.URL+"/page/0")
+
+	if got := records.Records(); len(got) != 0 {
+		t.Errorf("%d records, want none", len(got))
+	}
+	if len(hc.Emails) != 0 {
+		t.Errorf("Emails = %v, want none", hc.Emails)
+	}
+	if counts := hc.ClassCounts(); counts[ClassPlaceholder] != testSitePages || len(counts) != 1 {
+		t.Errorf("ClassCounts() = %v, want %d %s", counts, testSitePages, ClassPlaceholder)
+	}
+}
@@ -0,0 +1,196 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"mime"
+	"net/mail"
+	"sort"
+	"strings"
+)
+
+// DefaultContentTypes are the media types crawled unless given otherwise:
+// html, and the text types of contentFormats.
+var DefaultContentTypes = []string{
+	"text/html", "application/xhtml+xml", "text/plain",
+	"application/json", "application/ld+json",
+	"application/xml", "text/xml", "application/rss+xml", "application/atom+xml",
+	"text/vcard", "text/x-vcard", "text/calendar",
+}
+
+// contentFormats read the text content of pages other than html, and the
+// contacts they list, by media type, or by extension when served as plain
+// text or anything.
+var contentFormats = []DocumentFormat{
+	{Name: "vcard", MediaTypes: []string{"text/vcard", "text/x-vcard", "text/directory"}, Extensions: []string{".vcf", ".vcard"}, Read: ReadVCard},
+	{Name: "ical", MediaTypes: []string{"text/calendar"}, Extensions: []string{".ics", ".ical"}, Read: ReadICal},
+	jsonFormat,
+	xmlFormat,
+	textFormat,
+}
+
+var (
+	jsonFormat = DocumentFormat{Name: "json", MediaTypes: []string{"application/json", "application/ld+json", "text/json"}, Extensions: []string{".json", ".jsonld"}, Read: ReadJSON}
+	xmlFormat  = DocumentFormat{Name: "xml", MediaTypes: []string{"application/xml", "text/xml", "application/rss+xml", "application/atom+xml"}, Extensions: []string{".xml", ".rss", ".atom"}, Read: ReadXML}
+	textFormat = DocumentFormat{Name: "text", MediaTypes: []string{"text/plain"}, Extensions: []string{".txt"}, Read: ReadText}
+)
+
+// isHTML reports whether mediaType is crawled as an html page.
+func isHTML(mediaType string) bool {
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
+
+// contentFormatOf returns the format of content served as contentType from
+// rawURL, and its media type: the one served, or the one of the format of
+// the extension of rawURL when served as plain text or anything. Other
+// types, json and xml ones like application/vnd.api+json included, are
+// read as text.
+func contentFormatOf(contentType, rawURL string) (DocumentFormat, string) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "", "text/plain", "application/octet-stream", "binary/octet-stream":
+		if format, ok := documentFormatByExtension(contentFormats, rawURL); ok {
+			return format, format.MediaTypes[0]
+		}
+	}
+	for _, format := range contentFormats {
+		if StringInSlice(mediaType, format.MediaTypes) {
+			return format, mediaType
+		}
+	}
+	switch {
+	case strings.HasSuffix(mediaType, "+json"):
+		return jsonFormat, mediaType
+	case strings.HasSuffix(mediaType, "+xml"):
+		return xmlFormat, mediaType
+	}
+	return textFormat, mediaType
+}
+
+// contentTypeAllowed reports whether mediaType is one of patterns, exactly
+// or by a type/* or */* pattern.
+func contentTypeAllowed(mediaType string, patterns []string) bool {
+	if mediaType == "" {
+		return false
+	}
+	for _, pattern := range patterns {
+		switch {
+		case pattern == mediaType, pattern == "*/*":
+			return true
+		case strings.HasSuffix(pattern, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(pattern, "*")):
+			return true
+		}
+	}
+	return false
+}
+
+// ReadText returns plain text as it is.
+func ReadText(data []byte) (*Document, error) {
+	return &Document{Text: string(data)}, nil
+}
+
+// ReadJSON returns the strings of json, unescaped, one per line, with the
+// contacts of the schema.org things in it. Malformed json, like jsonp, is
+// read as text.
+func ReadJSON(data []byte) (*Document, error) {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return ReadText(data)
+	}
+	text := strings.Builder{}
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case string:
+			text.WriteString(v)
+			text.WriteString("\n")
+		case []any:
+			for _, item := range v {
+				walk(item)
+			}
+		case map[string]any:
+			keys := []string{}
+			for key := range v {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				walk(v[key])
+			}
+		}
+	}
+	walk(v)
+	return &Document{Text: text.String(), contacts: jsonLDValueItems(v)}, nil
+}
+
+// ReadXML returns the text of xml, like rss and atom feeds, with the title
+// and the authors of feeds and their entries as contacts.
+func ReadXML(data []byte) (*Document, error) {
+	doc := &Document{}
+	text := strings.Builder{}
+	if err := readXMLText(bytes.NewReader(data), doc, &text); err != nil {
+		return nil, err
+	}
+	doc.Text = text.String()
+
+	d := xml.NewDecoder(bytes.NewReader(data))
+	d.Strict = false
+	// an atom author or contributor, with name and email elements
+	var person *contactItem
+	value := strings.Builder{}
+	for {
+		token, err := d.Token()
+		if err != nil {
+			break
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			value.Reset()
+			if t.Name.Local == "author" || t.Name.Local == "contributor" {
+				person = &contactItem{method: MethodFeed}
+			}
+		case xml.CharData:
+			value.Write(t)
+		case xml.EndElement:
+			v := strings.TrimSpace(value.String())
+			value.Reset()
+			switch t.Name.Local {
+			case "title":
+				if doc.Title == "" {
+					doc.Title = collapseSpace(v)
+				}
+			case "name":
+				if person != nil {
+					person.name = collapseSpace(v)
+				}
+			case "email":
+				if person != nil {
+					person.emails = append(person.emails, v)
+				}
+			case "author", "contributor", "managingEditor", "webMaster", "creator":
+				if person != nil && len(person.emails) > 0 {
+					doc.contacts = append(doc.contacts, *person)
+				} else if item, ok := feedPerson(v); ok {
+					doc.contacts = append(doc.contacts, item)
+				}
+				if t.Name.Local == "author" || t.Name.Local == "contributor" {
+					person = nil
+				}
+			}
+		}
+	}
+	return doc, nil
+}
+
+// feedPerson returns the contact of an rss person, like
+// jane@example.com (Jane Roe) or Jane Roe <jane@example.com>.
+func feedPerson(s string) (contactItem, bool) {
+	if email, name, ok := strings.Cut(s, " ("); ok && strings.Contains(email, "@") {
+		return contactItem{method: MethodFeed, emails: []string{email}, name: strings.TrimSuffix(name, ")")}, true
+	}
+	if addr, err := mail.ParseAddress(s); err == nil {
+		return contactItem{method: MethodFeed, emails: []string{addr.Address}, name: addr.Name}, true
+	}
+	return contactItem{}, false
+}
@@ -0,0 +1,240 @@
+package pkg
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testVCard = "BEGIN:VCARD\r\n" +
+	"VERSION:3.0\r\n" +
+	"N:Roe;Jane;;;\r\n" +
+	"EMAIL;TYPE=\"work,pref\":jane.roe@acme.com\r\n" +
+	"item1.EMAIL;TYPE=home:jane@home.example.org\r\n" +
+	"TEL;TYPE=work:+1 415 555 0100\r\n" +
+	"TITLE:Head of Sales\\, West\r\n" +
+	"ORG:Acme\\, Inc.;Sales\r\n" +
+	"NOTE:Long notes fold onto the next li\r\n" +
+	" ne\r\n" +
+	"END:VCARD\r\n" +
+	"BEGIN:VCARD\r\n" +
+	"VERSION:2.1\r\n" +
+	"FN:Sales Desk\r\n" +
+	"EMAIL;INTERNET:sales@acme.com\r\n" +
+	"END:VCARD\r\n" +
+	"BEGIN:VCARD\r\n" +
+	"FN:No Contact\r\n" +
+	"END:VCARD\r\n"
+
+func TestReadVCard(t *testing.T) {
+	doc, err := ReadVCard([]byte(testVCard))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []contactItem{
+		{
+			method:       MethodVCard,
+			emails:       []string{"jane.roe@acme.com", "jane@home.example.org"},
+			phones:       []string{"+1 415 555 0100"},
+			name:         "Jane Roe",
+			jobTitle:     "Head of Sales, West",
+			organization: "Acme, Inc.",
+		},
+		{method: MethodVCard, emails: []string{"sales@acme.com"}, name: "Sales Desk"},
+	}
+	if !reflect.DeepEqual(doc.contacts, want) {
+		t.Errorf("contacts = %+v, want %+v", doc.contacts, want)
+	}
+	if !strings.Contains(doc.Text, "next line") {
+		t.Errorf("Text = %q, want it unfolded", doc.Text)
+	}
+}
+
+func TestReadICal(t *testing.T) {
+	doc, err := ReadICal([]byte("BEGIN:VCALENDAR\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"SUMMARY:Quarterly review\r\n" +
+		"ORGANIZER;CN=\"Roe, Jane\":mailto:jane.roe@acme.com\r\n" +
+		"ATTENDEE;ROLE=REQ-PARTICIPANT;CN=Alan Smithee:MAILTO:alan@acme.c\r\n" +
+		" om\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Title != "Quarterly review" {
+		t.Errorf("Title = %q, want %q", doc.Title, "Quarterly review")
+	}
+	want := []contactItem{
+		{method: MethodICal, emails: []string{"mailto:jane.roe@acme.com"}, name: "Roe, Jane"},
+		{method: MethodICal, emails: []string{"MAILTO:alan@acme.com"}, name: "Alan Smithee"},
+	}
+	if !reflect.DeepEqual(doc.contacts, want) {
+		t.Errorf("contacts = %+v, want %+v", doc.contacts, want)
+	}
+}
+
+func TestReadXMLFeeds(t *testing.T) {
+	rss := `<?xml version="1.0"?>
+<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/"><channel>
+<title>Acme News</title>
+<managingEditor>editor@acme.com (Ed Itor)</managingEditor>
+<item><title>Launch</title><author>Jane Roe &lt;jane.roe@acme.com&gt;</author><dc:creator>Alan Smithee</dc:creator>
+<description>Write to press@acme.com</description></item>
+</channel></rss>`
+	doc, err := ReadXML([]byte(rss))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Title != "Acme News" {
+		t.Errorf("Title = %q, want %q", doc.Title, "Acme News")
+	}
+	want := []contactItem{
+		{method: MethodFeed, emails: []string{"editor@acme.com"}, name: "Ed Itor"},
+		{method: MethodFeed, emails: []string{"jane.roe@acme.com"}, name: "Jane Roe"},
+	}
+	if !reflect.DeepEqual(doc.contacts, want) {
+		t.Errorf("rss contacts = %+v, want %+v", doc.contacts, want)
+	}
+	if !strings.Contains(doc.Text, "Write to press@acme.com") {
+		t.Errorf("Text = %q, want the description", doc.Text)
+	}
+
+	atom := `<feed xmlns="http://www.w3.org/2005/Atom"><title>Acme Blog</title>
+<author><name>Jane Roe</name><email>jane.roe@acme.com</email></author>
+<entry><title>Hello</title><contributor><name>Alan</name></contributor></entry>
+</feed>`
+	doc, err = ReadXML([]byte(atom))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []contactItem{{method: MethodFeed, emails: []string{"jane.roe@acme.com"}, name: "Jane Roe"}}
+	if doc.Title != "Acme Blog" || !reflect.DeepEqual(doc.contacts, want) {
+		t.Errorf("atom = %q %+v, want %q %+v", doc.Title, doc.contacts, "Acme Blog", want)
+	}
+}
+
+func TestReadJSON(t *testing.T) {
+	doc, err := ReadJSON([]byte(`{"team": [{"bio": "Reach me at jane.roe@acme.com"}],
+		"org": {"@type": "Organization", "name": "Acme", "email": "info@acme.com"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(doc.Text, "Reach me at jane.roe@acme.com\n") {
+		t.Errorf("Text = %q, want the strings of the json", doc.Text)
+	}
+	want := []contactItem{{method: MethodJSONLD, emails: []string{"info@acme.com"}, name: "Acme", organization: "Acme"}}
+	if !reflect.DeepEqual(doc.contacts, want) {
+		t.Errorf("contacts = %+v, want %+v", doc.contacts, want)
+	}
+
+	doc, err = ReadJSON([]byte(`callback({"email": "jsonp@acme.com"})`))
+	if err != nil || !strings.Contains(doc.Text, "jsonp@acme.com") {
+		t.Errorf("ReadJSON(jsonp) = %+v, %v, want it read as text", doc, err)
+	}
+}
+
+func TestContentFormatOf(t *testing.T) {
+	tests := []struct {
+		contentType, url  string
+		format, mediaType string
+	}{
+		{"text/html; charset=utf-8", "https://acme.com/", "text", "text/html"},
+		{"text/vcard", "https://acme.com/jane", "vcard", "text/vcard"},
+		{"text/plain", "https://acme.com/jane.vcf", "vcard", "text/vcard"},
+		{"application/octet-stream", "https://acme.com/events.ics?x=1", "ical", "text/calendar"},
+		{"text/plain", "https://acme.com/contact.txt", "text", "text/plain"},
+		{"application/vnd.api+json", "https://acme.com/api", "json", "application/vnd.api+json"},
+		{"application/rss+xml", "https://acme.com/feed", "xml", "application/rss+xml"},
+		{"image/png", "https://acme.com/logo.vcf", "text", "image/png"},
+	}
+	for _, tt := range tests {
+		format, mediaType := contentFormatOf(tt.contentType, tt.url)
+		if format.Name != tt.format || mediaType != tt.mediaType {
+			t.Errorf("contentFormatOf(%q, %q) = %q, %q, want %q, %q", tt.contentType, tt.url, format.Name, mediaType, tt.format, tt.mediaType)
+		}
+	}
+}
+
+func TestContentTypeAllowed(t *testing.T) {
+	patterns := []string{"text/html", "application/*"}
+	for mediaType, want := range map[string]bool{
+		"text/html":            true,
+		"application/json":     true,
+		"application/rss+xml":  true,
+		"text/plain":           false,
+		"applicationx/unknown": false,
+		"":                     false,
+	} {
+		if got := contentTypeAllowed(mediaType, patterns); got != want {
+			t.Errorf("contentTypeAllowed(%q) = %v, want %v", mediaType, got, want)
+		}
+	}
+	if !contentTypeAllowed("image/png", []string{"*/*"}) {
+		t.Error("contentTypeAllowed(image/png, */*) = false, want true")
+	}
+}
+
+func TestCrawlContentTypes(t *testing.T) {
+	serve := func(mux *http.ServeMux, path, contentType, body string) {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			_, _ = w.Write([]byte(body))
+		})
+	}
+	mux := http.NewServeMux()
+	serve(mux, "/", "text/html", `<html><body><a href="/team.vcf">team</a> <a href="/events">events</a>
+<a href="/feed">feed</a> <a href="/api/people">api</a> <a href="/contact.txt">contact</a> <a href="/logo">logo</a></body></html>`)
+	serve(mux, "/team.vcf", "text/plain", testVCard)
+	serve(mux, "/events", "text/calendar", "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nORGANIZER;CN=Ann Org:mailto:ann@acme.com\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n")
+	serve(mux, "/feed", "application/rss+xml", `<rss><channel><title>News</title><item><author>writer@acme.com (Wri Ter)</author></item></channel></rss>`)
+	serve(mux, "/api/people", "application/json", `[{"contact": "api@acme.com"}]`)
+	serve(mux, "/contact.txt", "text/plain; charset=utf-8", "Write to plain@acme.com")
+	serve(mux, "/logo", "image/png", "logo@acme.com")
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+
+	crawl := func(contentTypes []string) map[string]Record {
+		hc := newTestHTTPChallenge(ts, "", false)
+		hc.options.URL = ts.URL
+		hc.options.ContentTypes = contentTypes
+		records := &recordSink{}
+		hc.AddSink(records)
+		hc.CrawlRecursiveParallel(context.Background(), ts.URL)
+
+		got := map[string]Record{}
+		for _, r := range records.Records() {
+			got[r.Value] = r
+		}
+		return got
+	}
+
+	got := crawl(DefaultContentTypes)
+	want := map[string]string{
+		"jane.roe@acme.com":     MethodVCard,
+		"jane@home.example.org": MethodVCard,
+		"sales@acme.com":        MethodVCard,
+		"ann@acme.com":          MethodICal,
+		"writer@acme.com":       MethodFeed,
+		"api@acme.com":          MethodText,
+		"plain@acme.com":        MethodText,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("records = %v, want %v", got, want)
+	}
+	for email, method := range want {
+		if r := got[email]; r.Method != method || r.SourceURL == ts.URL {
+			t.Errorf("%s = %+v, want method %q and the linked source", email, r, method)
+		}
+	}
+	if r := got["jane.roe@acme.com"]; r.Name != "Jane Roe" || r.JobTitle != "Head of Sales, West" || r.Organization != "Acme, Inc." {
+		t.Errorf("jane.roe@acme.com = %+v, want the name, title and organization of the vcard", r)
+	}
+
+	if got := crawl([]string{"text/html"}); len(got) != 0 {
+		t.Errorf("records of html only = %v, want none", got)
+	}
+}
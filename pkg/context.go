@@ -0,0 +1,232 @@
+package pkg
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// Context is where on its page a finding was: a snippet of the text around
+// it, the nearest heading before it, and the name of the person or
+// organization it belongs to, and their job title and organization, if the
+// page tells.
+type Context struct {
+	Snippet      string
+	Heading      string
+	Name         string
+	JobTitle     string
+	Organization string
+}
+
+// merge fills the empty fields of c from other.
+func (c Context) merge(other Context) Context {
+	for _, f := range []struct {
+		field *string
+		value string
+	}{
+		{&c.Snippet, other.Snippet},
+		{&c.Heading, other.Heading},
+		{&c.Name, other.Name},
+		{&c.JobTitle, other.JobTitle},
+		{&c.Organization, other.Organization},
+	} {
+		if *f.field == "" {
+			*f.field = f.value
+		}
+	}
+	return c
+}
+
+const (
+	blockSelector  = "p, li, td, th, dd, dt, address, blockquote, figcaption, div, section, article, aside, header, footer, form"
+	nameSelector   = `[itemprop="name"], .name, .fn, strong, b`
+	schemaSelector = `[itemtype*="schema.org/Person"], [itemtype*="schema.org/Organization"]`
+)
+
+// nameStopWords are words of link texts and labels which are not names,
+// like Contact Us or Send Email.
+var nameStopWords = []string{"about", "call", "click", "contact", "e-mail", "email", "here", "info", "mail", "me", "more", "our", "phone", "read", "sales", "send", "support", "the", "to", "us", "write"}
+
+// ElementContext returns the context of needle, found in or as s: the
+// snippet of up to window characters each side of it in the text of its
+// block, the nearest heading and the name near it.
+func ElementContext(s *goquery.Selection, needle string, window int) Context {
+	block := s.Closest(blockSelector)
+	if block.Length() == 0 {
+		block = s
+	}
+	c := Context{Heading: nearestHeading(s.Get(0)), Name: nameNear(s, block)}
+	if window > 0 {
+		text := collapseSpace(block.Text())
+		if !strings.Contains(text, needle) {
+			// like the text of a mailto link, Jane Roe, instead of the address
+			needle = collapseSpace(s.Text())
+		}
+		c.Snippet = snippet(text, needle, window)
+	}
+	return c
+}
+
+// TextContext returns the context of the first text of dom with needle,
+// or an empty one if no text has it.
+func TextContext(dom *goquery.Selection, needle string, window int) Context {
+	var found *html.Node
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil && found == nil; c = c.NextSibling {
+			switch {
+			case c.Type == html.TextNode && strings.Contains(c.Data, needle):
+				found = n
+			case c.Type == html.ElementNode && c.Data != "script" && c.Data != "style":
+				walk(c)
+			}
+		}
+	}
+	for _, n := range dom.Nodes {
+		walk(n)
+	}
+	if found == nil {
+		return Context{}
+	}
+	return ElementContext(dom.FindNodes(found), needle, window)
+}
+
+// snippet returns up to window runes each side of needle in text, with
+// ellipses where cut, at word boundaries.
+func snippet(text, needle string, window int) string {
+	i := strings.Index(text, needle)
+	if i < 0 || needle == "" {
+		i, needle = 0, ""
+	}
+	head, tail := text[:i], text[i+len(needle):]
+	if r := []rune(head); len(r) > window {
+		head = string(r[len(r)-window:])
+		if j := strings.IndexByte(head, ' '); j >= 0 {
+			head = head[j+1:]
+		}
+		head = "…" + head
+	}
+	if r := []rune(tail); len(r) > window {
+		tail = string(r[:window])
+		if j := strings.LastIndexByte(tail, ' '); j >= 0 {
+			tail = tail[:j]
+		}
+		tail += "…"
+	}
+	return head + needle + tail
+}
+
+// nearestHeading returns the text of the heading n is in or, going back in
+// the document, the last before it.
+func nearestHeading(n *html.Node) string {
+	for ; n != nil; n = n.Parent {
+		if isHeading(n) {
+			return collapseSpace(nodeText(n))
+		}
+		for sib := n.PrevSibling; sib != nil; sib = sib.PrevSibling {
+			if h := lastHeading(sib); h != nil {
+				return collapseSpace(nodeText(h))
+			}
+		}
+	}
+	return ""
+}
+
+func lastHeading(n *html.Node) *html.Node {
+	if isHeading(n) {
+		return n
+	}
+	for c := n.LastChild; c != nil; c = c.PrevSibling {
+		if h := lastHeading(c); h != nil {
+			return h
+		}
+	}
+	return nil
+}
+
+func isHeading(n *html.Node) bool {
+	return n.Type == html.ElementNode && len(n.Data) == 2 && n.Data[0] == 'h' && n.Data[1] >= '1' && n.Data[1] <= '6'
+}
+
+func nodeText(n *html.Node) string {
+	b := strings.Builder{}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return b.String()
+}
+
+// nameNear returns the name of the schema.org Person or Organization s is
+// in, the text of s if it is a link reading like a name, or the first name
+// like text of a name or bold element in or just before block.
+func nameNear(s, block *goquery.Selection) string {
+	if item := s.Closest(schemaSelector); item.Length() > 0 {
+		if name := collapseSpace(item.Find(`[itemprop="name"]`).First().Text()); name != "" {
+			return name
+		}
+	}
+	if goquery.NodeName(s) == "a" {
+		if name := collapseSpace(s.Text()); looksLikeName(name) {
+			return name
+		}
+	}
+	prev := block.Prev()
+	candidates := block.Find(nameSelector).AddSelection(prev.Filter(nameSelector)).AddSelection(prev.Find(nameSelector))
+	name := ""
+	candidates.EachWithBreak(func(_ int, n *goquery.Selection) bool {
+		if text := collapseSpace(n.Text()); looksLikeName(text) {
+			name = text
+			return false
+		}
+		return true
+	})
+	return name
+}
+
+// looksLikeName reports whether text reads like the name of a person or
+// organization: two to five capitalized words, without digits, addresses or
+// the words of labels.
+func looksLikeName(text string) bool {
+	words := strings.Fields(text)
+	if len(words) < 2 || len(words) > 5 || len(text) > 60 {
+		return false
+	}
+	for _, w := range words {
+		if r, _ := utf8.DecodeRuneInString(w); !unicode.IsUpper(r) {
+			return false
+		}
+		if strings.ContainsAny(w, "@:/0123456789") || StringInSlice(strings.ToLower(strings.Trim(w, ".,")), nameStopWords) {
+			return false
+		}
+	}
+	return true
+}
+
+// JSONLDNames returns the names of the people and organizations with an
+// email of the json-ld scripts of dom by their lowercased email.
+func JSONLDNames(dom *goquery.Selection) map[string]string {
+	names := map[string]string{}
+	for _, item := range jsonLDItems(dom) {
+		if item.name == "" {
+			continue
+		}
+		for _, email := range item.emails {
+			names[strings.ToLower(strings.TrimPrefix(email, "mailto:"))] = item.name
+		}
+	}
+	return names
+}
+
+func collapseSpace(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
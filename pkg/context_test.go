@@ -0,0 +1,71 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const testTeamPage = `<html><head>
+<script type="application/ld+json">{"@context": "https://schema.org", "@type": "Organization", "name": "Acme Corp",
+  "employee": [{"@type": "Person", "name": "Ada Lovelace", "email": "mailto:ada@acme.com"}]}</script>
+</head><body>
+<h1>Acme</h1>
+<h2>Our team</h2>
+<div class="card"><strong>Grace Hopper</strong><p>Rear admiral, reach her at grace@acme.com any time.</p></div>
+<p>Questions? <a href="mailto:alan@acme.com">Alan Turing</a> answers them.</p>
+<section><h3>Press</h3><div itemscope itemtype="https://schema.org/Person"><span itemprop="name">Linus Pauling</span> <span>linus@acme.com</span></div></section>
+<p>Ada writes at ada@acme.com too.</p>
+<h2>Support</h2><p>Write to <a href="mailto:help@acme.com">Email Us</a> for help.</p>
+</body></html>`
+
+func TestExtractContext(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(testTeamPage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	extractors, err := NewExtractors([]string{"emails"}, &CrawlOptions{SnippetWindow: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]Context{}
+	for _, f := range extractors[0].Extract(&Page{URL: "https://acme.com/team", Body: testTeamPage, DOM: doc.Selection}) {
+		got[f.Value] = f.Context
+	}
+
+	want := map[string]Context{
+		"grace@acme.com": {Snippet: "…reach her at grace@acme.com any time.", Heading: "Our team", Name: "Grace Hopper"},
+		"alan@acme.com":  {Snippet: "Questions? Alan Turing answers them.", Heading: "Our team", Name: "Alan Turing"},
+		"linus@acme.com": {Snippet: "Linus Pauling linus@acme.com", Heading: "Press", Name: "Linus Pauling"},
+		"ada@acme.com":   {Snippet: "Ada writes at ada@acme.com too.", Heading: "Press", Name: "Ada Lovelace"},
+		"help@acme.com":  {Snippet: "Write to Email Us for help.", Heading: "Support"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Extract() = %v, want %v", got, want)
+	}
+	for email, c := range want {
+		if got[email] != c {
+			t.Errorf("context of %s = %+v, want %+v", email, got[email], c)
+		}
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	tests := []struct {
+		text     string
+		needle   string
+		window   int
+		expected string
+	}{
+		{"write to jane@example.com", "jane@example.com", 40, "write to jane@example.com"},
+		{"one two three four jane@example.com five six seven eight", "jane@example.com", 10, "…four jane@example.com five six…"},
+		{"één twee drie vier", "drie", 6, "…twee drie vier"},
+		{"no needle here", "jane@example.com", 5, "no…"},
+	}
+	for _, test := range tests {
+		if got := snippet(test.text, test.needle, test.window); got != test.expected {
+			t.Errorf("snippet(%q, %q, %d) = %q, want %q", test.text, test.needle, test.window, got, test.expected)
+		}
+	}
+}
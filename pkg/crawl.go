@@ -1,470 +1,1057 @@
 package pkg
 
 import (
-	"encoding/json"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"runtime"
+	"net/http"
+	"net/http/cookiejar"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/gookit/color"
-	"github.com/headzoo/surf"
-	"github.com/headzoo/surf/browser"
-	"github.com/labstack/echo/v4"
 )
 
+// CrawlOptions are the options of a crawl. The json names are the ones of
+// the command line flags, output files are left to the command line.
 type CrawlOptions struct {
-	TimeoutMillisecond int64
-	SleepMillisecond   int64
-	URL                string
-	IgnoreQueries      bool
-	Depth              int
-	LimitUrls          int
-	LimitEmails        int
-	WriteToFile        string
-	CrawlFromFile      bool
-	MaxWorkers         int
+	TimeoutMillisecond int64    `json:"timeout"`
+	SleepMillisecond   int64    `json:"sleep"`
+	URL                string   `json:"url"`
+	IgnoreQueries      bool     `json:"ignore_queries"`
+	Depth              int      `json:"depth"`
+	LimitUrls          int      `json:"limit_urls"`
+	LimitEmails        int      `json:"limit_emails"`
+	WriteToFile        string   `json:"-"`
+	Format             string   `json:"-"`
+	CrawlFromFile      bool     `json:"-"`
+	MaxWorkers         int      `json:"max_workers"`
+	IgnoreRobots       bool     `json:"ignore_robots"`
+	HostRate           float64  `json:"host_rps"`
+	HostMaxInFlight    int      `json:"host_max_inflight"`
+	Deobfuscate        []string `json:"deobfuscate"`
+	Extract            []string `json:"extract"`
+	SnippetWindow      int      `json:"snippet_window"`
+	Verify             bool     `json:"verify"`
+	Resolver           string   `json:"resolver"`
+	VerifyWorkers      int      `json:"verify_workers"`
+	Exclude            []string `json:"exclude"`
+	ClassLists         string   `json:"-"`
+	Documents          []string `json:"documents"`
+	MaxDocumentBytes   int64    `json:"max_document_bytes"`
+	MaxBodyBytes       int64    `json:"max_body_bytes"`
+	ContentTypes       []string `json:"content_types"`
+	Retries            int      `json:"retries"`
+	BackoffMillisecond int64    `json:"retry_backoff"`
+}
+
+// defaultMaxDocumentBytes is the size of the largest document downloaded
+// unless given otherwise, and defaultMaxBodyBytes the one of the largest
+// page, html or other content.
+const (
+	defaultMaxDocumentBytes = 20 << 20
+	defaultMaxBodyBytes     = 10 << 20
+)
+
+// DefaultCrawlOptions returns the options used unless given otherwise, the
+// defaults of the command line flags.
+func DefaultCrawlOptions() CrawlOptions {
+	return CrawlOptions{
+		TimeoutMillisecond: 10000,
+		IgnoreQueries:      true,
+		Depth:              -1,
+		LimitUrls:          1000,
+		LimitEmails:        1000,
+		Format:             FormatTXT,
+		MaxWorkers:         50,
+		HostRate:           10,
+		HostMaxInFlight:    5,
+		Deobfuscate:        DeobfuscatorNames(),
+		Extract:            []string{"emails", "structured"},
+		SnippetWindow:      80,
+		VerifyWorkers:      10,
+		MaxDocumentBytes:   defaultMaxDocumentBytes,
+		MaxBodyBytes:       defaultMaxBodyBytes,
+		ContentTypes:       DefaultContentTypes,
+		Retries:            2,
+		BackoffMillisecond: defaultRetryBackoff.Milliseconds(),
+	}
 }
 
 type CrawlOption func(*CrawlOptions) error
 
+type SkippedURL struct {
+	URL    string `json:"url"`
+	Reason string `json:"reason"`
+}
+
+// CrawlStats are the counters of a crawl so far.
+type CrawlStats struct {
+	URLsSeen    int `json:"urls_seen"`
+	URLsCrawled int `json:"urls_crawled"`
+	URLsFound   int `json:"urls_with_emails"`
+	URLsSkipped int `json:"urls_skipped"`
+	URLsFailed  int `json:"urls_failed"`
+	Emails      int `json:"emails"`
+}
+
 type HTTPChallenge struct {
-	browse *browser.Browser
+	client     *http.Client
+	robots     *Robots
+	scheduler  *HostScheduler
+	state      *CrawlState
+	sinks      []RecordSink
+	extractors []Extractor
+	documents  []DocumentFormat
+	classifier *Classifier
+	verifier   *MXVerifier
+	observer   func(Event)
 
+	// mu guards urls, depths, Emails, excluded, Findings, the counters,
+	// SkippedURLs, FailedURLs and stopped
+	mu               sync.Mutex
+	stopped          bool
 	urls             []string
+	depths           map[string]int
+	pending          []string
 	Emails           []string
+	excluded         map[string]string
+	Findings         map[string][]string
 	TotalURLsCrawled int
 	TotalURLsFound   int
+	SkippedURLs      []SkippedURL
+	FailedURLs       []FailedURL
 	options          *CrawlOptions
 }
 
-func NewHTTPChallenge(opts ...CrawlOption) *HTTPChallenge {
+// NewHTTPChallenge returns a crawler of the options set by opts, failing on
+// invalid ones, like unknown extract types or document formats.
+func NewHTTPChallenge(opts ...CrawlOption) (*HTTPChallenge, error) {
 	opt := &CrawlOptions{}
 	for _, o := range opts {
 		err := o(opt)
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
 	}
-	b := surf.NewBrowser()
-	b.SetUserAgent("GO kevincobain2000/email_extractor")
-	b.SetTimeout(time.Duration(opt.TimeoutMillisecond) * time.Millisecond)
+	if opt.Format == "" {
+		opt.Format = FormatTXT
+	}
+	// cookies are kept, like the sessions and consents of sites
+	cookies, _ := cookiejar.New(nil)
+	client := &http.Client{Timeout: time.Duration(opt.TimeoutMillisecond) * time.Millisecond, Jar: cookies}
 
-	return &HTTPChallenge{
-		browse:  b,
-		options: opt,
+	hc := &HTTPChallenge{
+		client:    client,
+		robots:    NewRobots(client, UserAgent),
+		scheduler: NewHostScheduler(opt.HostRate, opt.HostMaxInFlight, time.Duration(opt.SleepMillisecond)*time.Millisecond),
+		depths:    make(map[string]int),
+		excluded:  make(map[string]string),
+		Findings:  make(map[string][]string),
+		options:   opt,
+	}
+	hc.scheduler.SetCrawlDelayFunc(func(ctx context.Context, u string) time.Duration {
+		if hc.options.IgnoreRobots {
+			return 0
+		}
+		// an unreachable robots.txt fails the urls of the host instead
+		txt, err := hc.robots.Get(ctx, u)
+		if err != nil {
+			return 0
+		}
+		return txt.CrawlDelay
+	})
+	if opt.Extract == nil {
+		opt.Extract = []string{"emails", "structured"}
+	}
+	extractors, err := NewExtractors(opt.Extract, opt)
+	if err != nil {
+		return nil, err
+	}
+	hc.extractors = extractors
+	if opt.MaxDocumentBytes <= 0 {
+		opt.MaxDocumentBytes = defaultMaxDocumentBytes
+	}
+	if opt.MaxBodyBytes <= 0 {
+		opt.MaxBodyBytes = defaultMaxBodyBytes
 	}
+	if opt.BackoffMillisecond <= 0 {
+		opt.BackoffMillisecond = defaultRetryBackoff.Milliseconds()
+	}
+	hc.robots.SetRetries(opt.Retries, time.Duration(opt.BackoffMillisecond)*time.Millisecond)
+	documents, err := ParseDocumentFormats(opt.Documents)
+	if err != nil {
+		return nil, err
+	}
+	hc.documents = documents
+	if len(opt.ContentTypes) == 0 {
+		opt.ContentTypes = DefaultContentTypes
+	}
+	if err := ParseClasses(opt.Exclude); err != nil {
+		return nil, err
+	}
+	classifier, err := NewClassifier(opt.ClassLists)
+	if err != nil {
+		return nil, err
+	}
+	hc.classifier = classifier
+	if opt.Verify {
+		hc.verifier = NewMXVerifier(opt.Resolver, opt.VerifyWorkers, client.Timeout)
+	}
+	if opt.WriteToFile != "" {
+		output, err := NewRecordWriter(opt.WriteToFile, opt.Format)
+		if err != nil {
+			return nil, err
+		}
+		hc.AddSink(output)
+	}
+	return hc, nil
 }
 
-func (hc *HTTPChallenge) CrawlRecursiveParallel(url string, wg *sync.WaitGroup) *HTTPChallenge {
-	defer wg.Done()
-	urls := hc.Crawl(url)
+// AddSink stores the emails found from now on to sink too, besides the
+// output file.
+func (hc *HTTPChallenge) AddSink(sink RecordSink) {
+	hc.sinks = append(hc.sinks, sink)
+}
+
+// Observe calls fn with the events of the crawl as they happen, from the
+// crawling goroutines.
+func (hc *HTTPChallenge) Observe(fn func(Event)) {
+	hc.observer = fn
+}
+
+func (hc *HTTPChallenge) emit(e Event) {
+	if hc.observer == nil {
+		return
+	}
+	hc.observer(e)
+}
 
-	var mu sync.Mutex
+// Close closes the sinks once the crawl is done, writing out what they
+// still hold.
+func (hc *HTTPChallenge) Close() error {
+	var errs []error
+	for _, sink := range hc.sinks {
+		errs = append(errs, sink.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Stop stops dispatching urls, the crawl methods return once the urls being
+// crawled are finished, their requests left to finish or time out. Cancelling
+// the ctx of a crawl cancels them too.
+func (hc *HTTPChallenge) Stop() {
+	hc.mu.Lock()
+	hc.stopped = true
+	hc.mu.Unlock()
+	hc.scheduler.Close()
+}
+
+// Stopped reports whether Stop was called.
+func (hc *HTTPChallenge) Stopped() bool {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	return hc.stopped
+}
+
+// CrawlRecursiveParallel crawls url and the links found on it recursively,
+// with -max-workers workers, until done, stopped or ctx is done.
+func (hc *HTTPChallenge) CrawlRecursiveParallel(ctx context.Context, url string) *HTTPChallenge {
+	return hc.CrawlRecursiveParallelURLs(ctx, []string{url})
+}
+
+// CrawlRecursiveParallelURLs crawls urls and the links found on them
+// recursively, within the url and email limits. Workers drain a frontier
+// queue, and the crawl ends once the frontier is empty and all workers are
+// idle. Once stopped, the urls being crawled are finished, and once ctx is
+// done, their requests are cancelled.
+func (hc *HTTPChallenge) CrawlRecursiveParallelURLs(ctx context.Context, urls []string) *HTTPChallenge {
+	if hc.options.MaxWorkers <= 0 {
+		hc.options.MaxWorkers = 50 // Default to 50 workers
+	}
 	for _, u := range urls {
-		// Only apply limits if not crawling from file
-		if !hc.options.CrawlFromFile {
-			if len(hc.urls) >= hc.options.LimitUrls {
-				break
-			}
-			if len(hc.Emails) >= hc.options.LimitEmails {
-				hc.Emails = hc.Emails[:hc.options.LimitEmails]
-				break
+		hc.enqueue(u, hc.depthOf(u))
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < hc.options.MaxWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				url, ok := hc.scheduler.Next(ctx)
+				if !ok {
+					return
+				}
+				for _, u := range hc.crawl(ctx, url) {
+					hc.enqueue(u, hc.depthOf(url)+1)
+				}
+				// children are queued, so a resumed crawl does not need this url
+				// again, unless interrupted
+				if ctx.Err() == nil {
+					hc.markDone(url)
+				}
+				hc.scheduler.Done(url)
+
+				if hc.emailLimitReached() {
+					hc.scheduler.Close()
+				}
 			}
-		}
-		if StringInSlice(u, hc.urls) {
-			continue
-		}
+		}()
+	}
+	wg.Wait()
+	return hc
+}
 
-		mu.Lock()
-		hc.urls = append(hc.urls, u)
-		mu.Unlock()
+// enqueue pushes url, found depth links away from the start urls, to the
+// frontier unless it was seen already or the url limit is reached.
+func (hc *HTTPChallenge) enqueue(url string, depth int) {
+	if !hc.claimURL(url, depth) {
+		return
+	}
+	hc.markQueued(url, depth)
+	hc.scheduler.Push(url)
+}
 
-		if runtime.NumGoroutine() > 10000 {
-			color.Warn.Print("Sleeping")
-			color.Secondary.Print("....................")
-			color.Secondary.Println(fmt.Sprintf("%ds (goroutines %d, exceeded limit)", 10, runtime.NumGoroutine()))
-			time.Sleep(10 * time.Second)
-			wg.Add(1)
-			go hc.CrawlRecursiveParallel(u, wg)
-		} else {
-			wg.Add(1)
-			go hc.CrawlRecursiveParallel(u, wg)
+// claimURL records url as seen at depth, and reports whether it is new and
+// within the url limit.
+func (hc *HTTPChallenge) claimURL(url string, depth int) bool {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	if !hc.options.CrawlFromFile && len(hc.urls) >= hc.options.LimitUrls {
+		return false
+	}
+	if StringInSlice(url, hc.urls) {
+		return false
+	}
+	hc.urls = append(hc.urls, url)
+	hc.depths[url] = depth
+	return true
+}
+
+// depthOf returns how many links away from the start urls url was found.
+func (hc *HTTPChallenge) depthOf(url string) int {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	return hc.depths[url]
+}
+
+func (hc *HTTPChallenge) CrawlRecursive(ctx context.Context, url string) *HTTPChallenge {
+	links := hc.Crawl(ctx, url)
+	if ctx.Err() != nil {
+		return hc
+	}
+	depth := hc.depthOf(url) + 1
+	// links are queued before url is done, so a resumed crawl finds the ones
+	// not crawled yet
+	for _, u := range links {
+		if !hc.HasURL(u) {
+			hc.markQueued(u, depth)
 		}
 	}
+	hc.markDone(url)
+	return hc.crawlRecursiveURLs(ctx, links, depth)
+}
+
+// CrawlRecursiveURLs crawls each url not seen yet recursively, within the
+// url and email limits, until done, stopped or ctx is done. Urls restored with
+// UseState keep the depth they were found at.
+func (hc *HTTPChallenge) CrawlRecursiveURLs(ctx context.Context, urls []string) *HTTPChallenge {
+	for _, u := range urls {
+		if !hc.HasURL(u) {
+			hc.markQueued(u, hc.depthOf(u))
+		}
+	}
+	for _, u := range urls {
+		hc.crawlRecursiveURLs(ctx, []string{u}, hc.depthOf(u))
+	}
 	return hc
 }
-func (hc *HTTPChallenge) CrawlRecursive(url string) *HTTPChallenge {
-	urls := hc.Crawl(url)
 
+func (hc *HTTPChallenge) crawlRecursiveURLs(ctx context.Context, urls []string, depth int) *HTTPChallenge {
 	for _, u := range urls {
-		// Only apply limits if not crawling from file
-		if !hc.options.CrawlFromFile {
-			if len(hc.urls) >= hc.options.LimitUrls {
-				break
-			}
-			if len(hc.Emails) >= hc.options.LimitEmails {
-				hc.Emails = hc.Emails[:hc.options.LimitEmails]
-				break
-			}
+		if ctx.Err() != nil || hc.Stopped() || hc.emailLimitReached() {
+			break
 		}
-		if StringInSlice(u, hc.urls) {
+		if !hc.claimURL(u, depth) {
 			continue
 		}
 
-		hc.urls = append(hc.urls, u)
-
-		hc.CrawlRecursive(u)
+		hc.CrawlRecursive(ctx, u)
 	}
 	return hc
 }
 
-func (hc *HTTPChallenge) CrawlRecursiveStream(url string, c echo.Context, enc *json.Encoder) *HTTPChallenge {
-	urls := hc.Crawl(url)
+// Crawl crawls url once its host is ready, and returns the links found on
+// it. Nothing is crawled if the crawl is stopped or ctx is done first.
+func (hc *HTTPChallenge) Crawl(ctx context.Context, url string) []string {
+	if hc.Stopped() || hc.scheduler.Acquire(ctx, url) != nil {
+		return []string{}
+	}
+	defer hc.scheduler.Release(url)
+	return hc.crawl(ctx, url)
+}
 
-	for _, u := range urls {
-		select {
-		case <-c.Request().Context().Done():
-			color.Secondary.Print("API.........................")
-			color.Warn.Println("Request Cancelled")
-			return nil
-		default:
-		}
-
-		// Only apply limits if not crawling from file
-		if !hc.options.CrawlFromFile {
-			if len(hc.urls) >= hc.options.LimitUrls {
-				c.Request().Context().Done()
-				return hc
-			}
-			if len(hc.Emails) >= hc.options.LimitEmails {
-				hc.Emails = hc.Emails[:hc.options.LimitEmails]
-				c.Request().Context().Done()
-				return hc
-			}
-		}
-		if StringInSlice(u, hc.urls) {
-			continue
-		}
-		if IsAnAsset(u) {
-			continue
+// crawl is Crawl for a worker holding a slot of the host of url.
+func (hc *HTTPChallenge) crawl(ctx context.Context, url string) []string {
+	urls := []string{}
+	page := hc.visit(ctx, url)
+	if page == nil {
+		return urls
+	}
+
+	// crawl the page and print all links
+	page.DOM.Find("a").Each(func(_ int, s *goquery.Selection) {
+		href, exists := s.Attr("href")
+		if !exists {
+			return
 		}
-		p := "status" + "_SPLIT_DELIMETER_" + u
-		err := enc.Encode(p)
-		if err != nil {
-			color.Secondary.Print("API.........................")
-			color.Danger.Println(err.Error())
+		href, ok := hc.acceptLink(RelativeToAbsoluteURL(href, url, GetBaseURL(url)))
+		if !ok {
+			return
 		}
-		c.Response().Flush()
+		urls = append(urls, href)
+	})
+	urls = UniqueStrings(urls)
+	return urls
+}
 
-		hc.urls = append(hc.urls, u)
+// acceptLink normalizes the absolute url href and reports whether it is on
+// the crawled domain and within the crawl depth.
+func (hc *HTTPChallenge) acceptLink(href string) (string, bool) {
+	if hc.options.IgnoreQueries {
+		href = RemoveAnyQueryParam(href)
+	}
+	href = RemoveAnyAnchors(href)
+	isSubset := IsSameDomain(hc.options.URL, href)
+	if !isSubset {
+		return href, false
+	}
 
-		err = hc.browse.Head(url)
-		if err != nil {
-			continue
+	if hc.options.Depth != -1 {
+		depth := URLDepth(href, hc.options.URL)
+		if depth == -1 {
+			return href, false
 		}
-		if !strings.HasPrefix(hc.browse.ResponseHeaders().Get("Content-Type"), "text/html") {
-			continue
+		if depth == 0 {
+			return href, false
 		}
-		err = hc.browse.Open(u)
-		if err != nil {
-			color.Secondary.Print("API.........................")
-			color.Danger.Println(err.Error())
-			continue
-		}
-
-		rawBody := hc.browse.Body()
-
-		emails := ExtractEmailsFromText(rawBody)
-		emails = FilterOutCommonExtensions(emails)
-		emails = UniqueStrings(emails)
-		hc.Emails = append(hc.Emails, emails...)
-		for _, email := range emails {
-			p := email + "_SPLIT_DELIMETER_" + u
-			err := enc.Encode(p)
-			if err != nil {
-				color.Secondary.Print("API.........................")
-				color.Danger.Println(err.Error())
-			}
-			c.Response().Flush()
+		if depth > hc.options.Depth {
+			return href, false
 		}
+	}
+	return href, true
+}
 
-		hc.CrawlRecursiveStream(u, c, enc)
+// CrawlSingleURL crawls url once its host is ready, without following
+// links. Nothing is crawled if the crawl is stopped or ctx is done first.
+func (hc *HTTPChallenge) CrawlSingleURL(ctx context.Context, url string) *HTTPChallenge {
+	if hc.Stopped() || hc.scheduler.Acquire(ctx, url) != nil {
+		return hc
 	}
+	defer hc.scheduler.Release(url)
+	hc.crawlSingleURL(ctx, url)
 	return hc
 }
 
-func (hc *HTTPChallenge) Crawl(url string) []string {
-	// check if url doesn't end with pdf, png or jpg
-	if IsAnAsset(url) {
-		return []string{}
+// crawlSingleURL is CrawlSingleURL for a worker holding a slot of the host
+// of url.
+func (hc *HTTPChallenge) crawlSingleURL(ctx context.Context, url string) {
+	hc.visit(ctx, url)
+	// an interrupted url is left for a resumed crawl
+	if ctx.Err() == nil {
+		hc.markDone(url)
 	}
+}
 
-	if hc.options.SleepMillisecond > 0 {
-		color.Secondary.Print("Sleeping")
-		color.Secondary.Print("....................")
-		color.Secondary.Println(fmt.Sprintf("%dms (sleeping before request)", hc.options.SleepMillisecond))
-		time.Sleep(time.Duration(hc.options.SleepMillisecond) * time.Millisecond)
+func (hc *HTTPChallenge) CrawlSingleURLParallel(ctx context.Context, url string, wg *sync.WaitGroup) *HTTPChallenge {
+	defer wg.Done()
+	return hc.CrawlSingleURL(ctx, url)
+}
+
+// visit gets url, and extracts, records and saves the emails of its html
+// page, document or other content. It returns the html page of url, nil for
+// other content or when failing. The slot of the host of url held by the
+// caller is given back once the response is read.
+func (hc *HTTPChallenge) visit(ctx context.Context, url string) *Page {
+	// check if url doesn't end with pdf, png or jpg, unless a document to crawl
+	if _, ok := documentFormatByExtension(hc.documents, url); IsAnAsset(url) && !ok {
+		return nil
+	}
+
+	if !hc.robotsGate(ctx, url) {
+		return nil
+	}
+
+	hc.emit(Event{Type: EventPageStarted, URL: url})
+	var what string
+	var limit int64
+	resp, category, attempts, err := hc.getWithRetries(ctx, url, func(resp *response) int64 {
+		what, limit = hc.bodyLimit(resp, url)
+		return limit
+	})
+	// the host is not requested anymore, so its slot is given back before
+	// the body is parsed and the emails verified
+	hc.scheduler.Release(url)
+	if errors.Is(err, errBodyTooLarge) {
+		hc.skip(url, fmt.Sprintf("%s larger than %d bytes", what, limit))
+		return nil
 	}
-	urls := []string{}
-	err := hc.browse.Head(url)
 	if err != nil {
-		return urls
+		// a request cancelled by a stopped crawl did not fail
+		if ctx.Err() == nil {
+			hc.fail(url, category, attempts, err)
+		}
+		return nil
 	}
-	if !strings.HasPrefix(hc.browse.ResponseHeaders().Get("Content-Type"), "text/html") {
-		return urls
+	defer resp.Body.Close()
+	if format, ok := documentFormatOf(hc.documents, resp.contentType, url); ok {
+		hc.visitDocument(ctx, url, resp, format)
+		return nil
+	}
+	format, mediaType := contentFormatOf(resp.contentType, url)
+	if !contentTypeAllowed(mediaType, hc.options.ContentTypes) {
+		Logger().WithField("url", url).Debugf("content type %q not crawled", mediaType)
+		return nil
+	}
+	if !isHTML(mediaType) {
+		hc.visitDocument(ctx, url, resp, format)
+		return nil
 	}
 
-	err = hc.browse.Open(url)
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(resp.data))
 	if err != nil {
-		return urls
+		hc.fail(url, FailureOther, attempts, err)
+		return nil
+	}
+	hc.emit(Event{Type: EventPageFetched, URL: url, Status: resp.StatusCode})
+	hc.crawled(url, resp.StatusCode)
+	rawBody, _ := doc.Find("body").Html()
+
+	page := &Page{
+		URL:    url,
+		Status: resp.StatusCode,
+		Header: resp.Header,
+		Title:  strings.TrimSpace(doc.Find("title").Text()),
+		Body:   rawBody,
+		DOM:    doc.Selection,
 	}
+	hc.record(ctx, page, hc.extract(page))
+	return page
+}
 
+// crawled counts url as crawled and prints its status.
+func (hc *HTTPChallenge) crawled(url string, status int) {
+	hc.mu.Lock()
 	hc.TotalURLsCrawled++
+	hc.mu.Unlock()
 
 	color.Secondary.Print("Crawling")
 	color.Secondary.Print("....................")
-	if hc.browse.StatusCode() >= 400 {
-		color.Danger.Print(hc.browse.StatusCode())
+	if status >= 400 {
+		color.Danger.Print(status)
 	} else {
-		color.Success.Print(hc.browse.StatusCode())
+		color.Success.Print(status)
 	}
 	color.Secondary.Println(" " + url)
-	rawBody := hc.browse.Body()
+}
 
-	emails := ExtractEmailsFromText(rawBody)
-	emails = FilterOutCommonExtensions(emails)
-	emails = UniqueStrings(emails)
-	if len(emails) > 0 {
-		hc.TotalURLsFound++
-		color.Note.Print("Emails")
-		color.Secondary.Print("......................")
-		color.Note.Println(fmt.Sprintf("(%d) %s", len(emails), url))
-		for _, email := range emails {
-			color.Note.Print("Emails")
-			color.Secondary.Print("......................")
-			color.Success.Println(email)
+// bodyLimit returns what resp of url is, a page or a document or other
+// content of a format, and how many bytes of its body to read at most: the
+// -max-document-bytes of documents, the -max-body-bytes of pages and other
+// content, and 0 for the bodies not read, of content types not crawled and
+// of failed documents.
+func (hc *HTTPChallenge) bodyLimit(resp *response, url string) (string, int64) {
+	if format, ok := documentFormatOf(hc.documents, resp.contentType, url); ok {
+		if resp.StatusCode >= 400 {
+			return format.Name, 0
 		}
-		fmt.Println()
+		return format.Name, hc.options.MaxDocumentBytes
 	}
-	if hc.options.WriteToFile != "" {
-		hc.Emails = append(hc.Emails, emails...)
-		hc.Emails = UniqueStrings(hc.Emails)
+	format, mediaType := contentFormatOf(resp.contentType, url)
+	switch {
+	case !contentTypeAllowed(mediaType, hc.options.ContentTypes):
+		return format.Name, 0
+	case isHTML(mediaType):
+		return "page", hc.options.MaxBodyBytes
+	case resp.StatusCode >= 400:
+		return format.Name, 0
 	}
+	return format.Name, hc.options.MaxBodyBytes
+}
 
-	// crawl the page and print all links
-	hc.browse.Find("a").Each(func(_ int, s *goquery.Selection) {
-		href, exists := s.Attr("href")
-		if !exists {
-			return
-		}
-		href = RelativeToAbsoluteURL(href, url, GetBaseURL(url))
+// visitDocument reads the document or other content of format of resp, its
+// body read by getWithRetries, and extracts, records and saves the emails of
+// its text and contacts, with url as their source.
+func (hc *HTTPChallenge) visitDocument(ctx context.Context, url string, resp *response, format DocumentFormat) {
+	hc.emit(Event{Type: EventPageFetched, URL: url, Status: resp.StatusCode})
+	hc.crawled(url, resp.StatusCode)
+	if resp.StatusCode >= 400 {
+		return
+	}
 
-		if hc.options.IgnoreQueries {
-			href = RemoveAnyQueryParam(href)
-		}
-		href = RemoveAnyAnchors(href)
-		isSubset := IsSameDomain(hc.options.URL, href)
-		if !isSubset {
-			return
-		}
+	doc, err := format.Read(resp.data)
+	if err != nil {
+		hc.emit(Event{Type: EventError, URL: url, Error: err.Error()})
+		color.Danger.Print("Document")
+		color.Secondary.Print("....................")
+		color.Danger.Println("Error reading", url+":", err)
+		return
+	}
+	page := &Page{
+		URL:      url,
+		Status:   resp.StatusCode,
+		Header:   resp.Header,
+		Title:    strings.TrimSpace(doc.Title),
+		Body:     doc.Text,
+		Links:    doc.Links,
+		contacts: doc.contacts,
+	}
+	hc.record(ctx, page, hc.extract(page))
+}
 
-		if hc.options.Depth != -1 {
-			depth := URLDepth(href, hc.options.URL)
-			if depth == -1 {
-				return
+// extract runs the extractors on page, and returns what they found once
+// with the first method it was found with.
+func (hc *HTTPChallenge) extract(page *Page) []Finding {
+	findings := []Finding{}
+	seen := map[Finding]int{}
+	for _, e := range hc.extractors {
+		for _, f := range e.Extract(page) {
+			key := Finding{Type: f.Type, Value: f.Value}
+			i, ok := seen[key]
+			if !ok {
+				seen[key] = len(findings)
+				findings = append(findings, f)
+				continue
 			}
-			if depth == 0 {
-				return
-			}
-			if depth > hc.options.Depth {
-				return
+			// keep the most confident finding, with the context of both
+			if Confidence(f.Method) > Confidence(findings[i].Method) {
+				f.Context = f.Context.merge(findings[i].Context)
+				findings[i] = f
+			} else {
+				findings[i].Context = findings[i].Context.merge(f.Context)
 			}
 		}
-		urls = append(urls, href)
-	})
-	urls = UniqueStrings(urls)
-	return urls
+	}
+	return findings
 }
 
-func (hc *HTTPChallenge) CrawlSingleURL(url string) *HTTPChallenge {
-	// check if url doesn't end with pdf, png or jpg
-	if IsAnAsset(url) {
-		return hc
+// record prints the findings of page, and records, saves and writes the
+// ones not found before.
+func (hc *HTTPChallenge) record(ctx context.Context, page *Page, findings []Finding) {
+	findings, classes := hc.classify(page, findings)
+	values := map[string][]string{}
+	for _, f := range findings {
+		values[f.Type] = append(values[f.Type], f.Value)
 	}
 
-	if hc.options.SleepMillisecond > 0 {
-		color.Secondary.Print("Sleeping")
-		color.Secondary.Print("....................")
-		color.Secondary.Println(fmt.Sprintf("%dms (sleeping before request)", hc.options.SleepMillisecond))
-		time.Sleep(time.Duration(hc.options.SleepMillisecond) * time.Millisecond)
+	if emails := values[RecordEmail]; len(emails) > 0 {
+		hc.mu.Lock()
+		hc.TotalURLsFound++
+		hc.mu.Unlock()
 	}
-
-	err := hc.browse.Head(url)
-	if err != nil {
-		return hc
+	mx := hc.verify(ctx, values[RecordEmail])
+	types := []string{}
+	for _, f := range findings {
+		if !StringInSlice(f.Type, types) {
+			types = append(types, f.Type)
+		}
 	}
-	if !strings.HasPrefix(hc.browse.ResponseHeaders().Get("Content-Type"), "text/html") {
-		return hc
+	for _, t := range types {
+		label := recordLabels[t]
+		if label == "" {
+			label = t
+		}
+		dots := strings.Repeat(".", max(1, 28-len(label)))
+		color.Note.Print(label)
+		color.Secondary.Print(dots)
+		color.Note.Println(fmt.Sprintf("(%d) %s", len(values[t]), page.URL))
+		for _, value := range values[t] {
+			color.Note.Print(label)
+			color.Secondary.Print(dots)
+			if class, ok := classes[value]; ok && t == RecordEmail {
+				color.Success.Print(value)
+				color.Secondary.Println(" " + strings.TrimSpace(class+" "+mx[value]))
+				continue
+			}
+			color.Success.Println(value)
+		}
+	}
+	if len(types) > 0 {
+		fmt.Println()
 	}
 
-	err = hc.browse.Open(url)
-	if err != nil {
-		return hc
+	// Add emails to memory, and save the ones not found before
+	emails := hc.addEmails(values[RecordEmail])
+	hc.saveEmails(page.URL, emails)
+	added := map[string][]string{RecordEmail: emails}
+	for _, f := range findings {
+		if f.Type != RecordEmail && hc.addFinding(f) {
+			added[f.Type] = append(added[f.Type], f.Value)
+		}
 	}
 
-	hc.TotalURLsCrawled++
+	records, pageRecords := []Record{}, []Record{}
+	now := time.Now().UTC()
+	depth := hc.depthOf(page.URL)
+	for _, f := range findings {
+		// emails past the email limit are not recorded, for the page either
+		if f.Type == RecordEmail && !hc.hasEmail(f.Value) {
+			continue
+		}
+		r := Record{
+			Type:         f.Type,
+			Value:        f.Value,
+			SourceURL:    page.URL,
+			PageTitle:    page.Title,
+			Status:       page.Status,
+			DiscoveredAt: now,
+			Depth:        depth,
+			Method:       f.Method,
+			Class:        classes[f.Value],
+			MX:           mx[f.Value],
+			Name:         f.Name,
+			Heading:      f.Heading,
+			Snippet:      f.Snippet,
+			JobTitle:     f.JobTitle,
+			Organization: f.Organization,
+			Confidence:   Confidence(f.Method),
+		}
+		pageRecords = append(pageRecords, r)
+		if StringInSlice(f.Value, added[f.Type]) {
+			records = append(records, r)
+		}
+	}
+	hc.writeRecords(records, pageRecords)
+	for i := range records {
+		if records[i].Type == RecordEmail {
+			hc.emit(Event{Type: EventEmailFound, URL: page.URL, Email: &records[i]})
+		}
+	}
+	stats := hc.Stats()
+	hc.emit(Event{Type: EventProgress, Stats: &stats})
+}
 
-	color.Secondary.Print("Crawling")
-	color.Secondary.Print("....................")
-	if hc.browse.StatusCode() >= 400 {
-		color.Danger.Print(hc.browse.StatusCode())
-	} else {
-		color.Success.Print(hc.browse.StatusCode())
+// recordLabels are the console labels of the record types.
+var recordLabels = map[string]string{
+	RecordEmail:       "Emails",
+	RecordPhone:       "Phones",
+	RecordSocial:      "Socials",
+	RecordContactForm: "Contact forms",
+}
+
+// addFinding adds f if not found yet, and reports whether it was.
+func (hc *HTTPChallenge) addFinding(f Finding) bool {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	if StringInSlice(f.Value, hc.Findings[f.Type]) {
+		return false
 	}
-	color.Secondary.Println(" " + url)
-	rawBody := hc.browse.Body()
+	hc.Findings[f.Type] = append(hc.Findings[f.Type], f.Value)
+	return true
+}
 
-	emails := ExtractEmailsFromText(rawBody)
-	emails = FilterOutCommonExtensions(emails)
-	emails = UniqueStrings(emails)
-	if len(emails) > 0 {
-		hc.TotalURLsFound++
-		color.Note.Print("Emails")
-		color.Secondary.Print("......................")
-		color.Note.Println(fmt.Sprintf("(%d) %s", len(emails), url))
-		for _, email := range emails {
-			color.Note.Print("Emails")
+// classify returns findings without the emails of excluded classes, and
+// the classes of the emails.
+func (hc *HTTPChallenge) classify(page *Page, findings []Finding) ([]Finding, map[string]string) {
+	kept := []Finding{}
+	classes := map[string]string{}
+	for _, f := range findings {
+		if f.Type != RecordEmail {
+			kept = append(kept, f)
+			continue
+		}
+		class := hc.classifier.Classify(f.Value)
+		if StringInSlice(class, hc.options.Exclude) {
+			Logger().WithField("url", page.URL).Debugf("excluded %s address %q", class, f.Value)
+			hc.mu.Lock()
+			hc.excluded[f.Value] = class
+			hc.mu.Unlock()
+			continue
+		}
+		classes[f.Value] = class
+		kept = append(kept, f)
+	}
+	return kept, classes
+}
+
+// verify returns the MX statuses of the domains of emails, when verifying,
+// until ctx is done.
+func (hc *HTTPChallenge) verify(ctx context.Context, emails []string) map[string]string {
+	statuses := map[string]string{}
+	if hc.verifier == nil {
+		return statuses
+	}
+	for _, email := range emails {
+		domain := email[strings.LastIndex(email, "@")+1:]
+		status, err := hc.verifier.Verify(ctx, domain)
+		if ctx.Err() != nil {
+			break
+		}
+		if err != nil {
+			color.Danger.Print("Verify")
 			color.Secondary.Print("......................")
-			color.Success.Println(email)
+			color.Danger.Println("Error verifying", domain+":", err)
+			continue
 		}
-		fmt.Println()
+		statuses[email] = status
 	}
-	
-	// Add emails to memory
-	hc.Emails = append(hc.Emails, emails...)
-	hc.Emails = UniqueStrings(hc.Emails)
+	return statuses
+}
 
-	// Save emails to file immediately if output file is specified
-	if hc.options.WriteToFile != "" && len(emails) > 0 {
-		// Append new emails to file
-		err := AppendEmailsToFile(emails, hc.options.WriteToFile)
+// writeRecords writes records, the ones not found before, to the output
+// file, if specified, and the other sinks as soon as they are found, and
+// pageRecords, all the ones of the page, to the PageRecordSinks.
+func (hc *HTTPChallenge) writeRecords(records, pageRecords []Record) {
+	for _, sink := range hc.sinks {
+		var err error
+		if pageSink, ok := sink.(PageRecordSink); ok {
+			err = pageSink.WritePage(pageRecords)
+		} else {
+			err = sink.Write(records)
+		}
 		if err != nil {
 			color.Danger.Print("File write")
 			color.Secondary.Print("....................")
-			color.Danger.Println("Error writing emails to file:", err)
+			color.Danger.Println("Error writing records:", err)
 		}
 	}
+}
 
-	return hc
+// robotsGate reports whether robots.txt allows crawling url. Disallowed
+// urls are recorded as skipped, and urls whose robots.txt could not be
+// requested as failed, like the request of the url would, with the attempts
+// of robots.txt.
+func (hc *HTTPChallenge) robotsGate(ctx context.Context, url string) bool {
+	if hc.options.IgnoreRobots {
+		return true
+	}
+	allowed, reason, err := hc.robots.Allowed(ctx, url)
+	var robotsErr *RobotsError
+	if errors.As(err, &robotsErr) {
+		hc.fail(url, classifyError(err), robotsErr.Attempts, err)
+		return false
+	}
+	if err != nil {
+		// stopped by ctx
+		return false
+	}
+	if !allowed {
+		hc.skip(url, reason)
+		return false
+	}
+	return true
 }
 
-func (hc *HTTPChallenge) CrawlSingleURLParallel(url string, wg *sync.WaitGroup) *HTTPChallenge {
-	defer wg.Done()
-	
-	// check if url doesn't end with pdf, png or jpg
-	if IsAnAsset(url) {
-		return hc
+func (hc *HTTPChallenge) skip(url, reason string) {
+	hc.mu.Lock()
+	hc.SkippedURLs = append(hc.SkippedURLs, SkippedURL{URL: url, Reason: reason})
+	hc.mu.Unlock()
+
+	color.Secondary.Print("Skipping")
+	color.Secondary.Print("....................")
+	color.Warn.Print(reason)
+	color.Secondary.Println(" " + url)
+}
+
+// fail records url as failed, with the category of its last error, after
+// attempts requests.
+func (hc *HTTPChallenge) fail(url, category string, attempts int, err error) {
+	hc.mu.Lock()
+	hc.FailedURLs = append(hc.FailedURLs, FailedURL{URL: url, Category: category, Error: err.Error(), Attempts: attempts})
+	hc.mu.Unlock()
+	hc.emit(Event{Type: EventError, URL: url, Error: err.Error()})
+
+	color.Danger.Print("Failed")
+	color.Secondary.Print("......................")
+	color.Danger.Printf("%s after %d attempts", category, attempts)
+	color.Secondary.Println(" " + url)
+}
+
+// UseState checkpoints the crawl to state and restores the progress of a
+// previous crawl recorded in it.
+func (hc *HTTPChallenge) UseState(state *CrawlState) {
+	hc.state = state
+	snapshot := state.Snapshot()
+
+	hc.urls = append(hc.urls, snapshot.Done...)
+	for url, depth := range snapshot.Depths {
+		hc.depths[url] = depth
 	}
+	hc.pending = snapshot.Pending
+	hc.Emails = append(hc.Emails, snapshot.Emails...)
+	hc.TotalURLsCrawled = snapshot.TotalURLsCrawled
+	hc.TotalURLsFound = snapshot.TotalURLsFound
+}
 
-	if hc.options.SleepMillisecond > 0 {
-		color.Secondary.Print("Sleeping")
-		color.Secondary.Print("....................")
-		color.Secondary.Println(fmt.Sprintf("%dms (sleeping before request)", hc.options.SleepMillisecond))
-		time.Sleep(time.Duration(hc.options.SleepMillisecond) * time.Millisecond)
+// PendingURLs returns the urls discovered but not crawled by the previous
+// crawl restored with UseState.
+func (hc *HTTPChallenge) PendingURLs() []string {
+	return hc.pending
+}
+
+func (hc *HTTPChallenge) markQueued(url string, depth int) {
+	if hc.state == nil {
+		return
 	}
+	hc.stateError(hc.state.Queued(url, depth))
+}
 
-	err := hc.browse.Head(url)
-	if err != nil {
-		return hc
+func (hc *HTTPChallenge) markDone(url string) {
+	if hc.state == nil {
+		return
 	}
-	if !strings.HasPrefix(hc.browse.ResponseHeaders().Get("Content-Type"), "text/html") {
-		return hc
+	hc.mu.Lock()
+	crawled, found := hc.TotalURLsCrawled, hc.TotalURLsFound
+	hc.mu.Unlock()
+	hc.stateError(hc.state.Done(url, crawled, found))
+}
+
+func (hc *HTTPChallenge) saveEmails(url string, emails []string) {
+	if hc.state == nil {
+		return
 	}
+	hc.stateError(hc.state.AddEmails(url, emails))
+}
 
-	err = hc.browse.Open(url)
-	if err != nil {
-		return hc
+func (hc *HTTPChallenge) stateError(err error) {
+	if err == nil {
+		return
 	}
+	color.Danger.Print("State write")
+	color.Secondary.Print("...................")
+	color.Danger.Println("Error writing crawl state:", err)
+}
 
-	var mu sync.Mutex
-	mu.Lock()
-	hc.TotalURLsCrawled++
-	mu.Unlock()
+// LimitReached reports whether the url or email limit has been reached.
+// Crawls from file have no limits.
+func (hc *HTTPChallenge) LimitReached() bool {
+	if hc.options.CrawlFromFile {
+		return false
+	}
+	hc.mu.Lock()
+	urlLimitReached := len(hc.urls) >= hc.options.LimitUrls
+	hc.mu.Unlock()
+	return urlLimitReached || hc.emailLimitReached()
+}
 
-	color.Secondary.Print("Crawling")
-	color.Secondary.Print("....................")
-	if hc.browse.StatusCode() >= 400 {
-		color.Danger.Print(hc.browse.StatusCode())
-	} else {
-		color.Success.Print(hc.browse.StatusCode())
+func (hc *HTTPChallenge) emailLimitReached() bool {
+	if hc.options.CrawlFromFile {
+		return false
 	}
-	color.Secondary.Println(" " + url)
-	rawBody := hc.browse.Body()
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	return len(hc.Emails) >= hc.options.LimitEmails
+}
 
-	emails := ExtractEmailsFromText(rawBody)
-	emails = FilterOutCommonExtensions(emails)
-	emails = UniqueStrings(emails)
-	if len(emails) > 0 {
-		mu.Lock()
-		hc.TotalURLsFound++
-		mu.Unlock()
-		color.Note.Print("Emails")
-		color.Secondary.Print("......................")
-		color.Note.Println(fmt.Sprintf("(%d) %s", len(emails), url))
-		for _, email := range emails {
-			color.Note.Print("Emails")
-			color.Secondary.Print("......................")
-			color.Success.Println(email)
+// addEmails adds the emails not found yet, up to the email limit, and
+// returns them.
+func (hc *HTTPChallenge) addEmails(emails []string) []string {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	added := []string{}
+	for _, email := range emails {
+		if !hc.options.CrawlFromFile && len(hc.Emails) >= hc.options.LimitEmails {
+			break
 		}
-		fmt.Println()
+		if StringInSlice(email, hc.Emails) {
+			continue
+		}
+		hc.Emails = append(hc.Emails, email)
+		added = append(added, email)
 	}
-	
-	mu.Lock()
-	hc.Emails = append(hc.Emails, emails...)
-	hc.Emails = UniqueStrings(hc.Emails)
-	mu.Unlock()
-
-	// Save emails to file immediately if output file is specified
-	if hc.options.WriteToFile != "" && len(emails) > 0 {
-		mu.Lock()
-		err := AppendEmailsToFile(emails, hc.options.WriteToFile)
-		mu.Unlock()
-		if err != nil {
-			color.Danger.Print("File write")
-			color.Secondary.Print("....................")
-			color.Danger.Println("Error writing emails to file:", err)
+	return added
+}
+
+// hasEmail reports whether email was added.
+func (hc *HTTPChallenge) hasEmail(email string) bool {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	return StringInSlice(email, hc.Emails)
+}
+
+// ClassCounts counts the emails, excluded ones included, per class.
+func (hc *HTTPChallenge) ClassCounts() map[string]int {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	counts := map[string]int{}
+	for _, email := range UniqueStrings(hc.Emails) {
+		counts[hc.classifier.Classify(email)]++
+	}
+	for _, class := range hc.excluded {
+		counts[class]++
+	}
+	return counts
+}
+
+// MXCounts counts the emails per MX status of their domains, nil unless verifying.
+func (hc *HTTPChallenge) MXCounts() map[string]int {
+	if hc.verifier == nil {
+		return nil
+	}
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	counts := map[string]int{}
+	for _, email := range hc.Emails {
+		if status := hc.verifier.Status(email[strings.LastIndex(email, "@")+1:]); status != "" {
+			counts[status]++
 		}
 	}
+	return counts
+}
 
-	return hc
+// Stats returns the counters of the crawl, safe to call while crawling.
+func (hc *HTTPChallenge) Stats() CrawlStats {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	return CrawlStats{
+		URLsSeen:    len(hc.urls),
+		URLsCrawled: hc.TotalURLsCrawled,
+		URLsFound:   hc.TotalURLsFound,
+		URLsSkipped: len(hc.SkippedURLs),
+		URLsFailed:  len(hc.FailedURLs),
+		Emails:      len(hc.Emails),
+	}
 }
 
 func (hc *HTTPChallenge) GetURLsCount() int {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
 	return len(hc.urls)
 }
 
 func (hc *HTTPChallenge) GetEmailsCount() int {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
 	return len(hc.Emails)
 }
 
 func (hc *HTTPChallenge) AddURL(url string) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
 	hc.urls = append(hc.urls, url)
 }
 
 func (hc *HTTPChallenge) HasURL(url string) bool {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
 	return StringInSlice(url, hc.urls)
 }
 
-func (hc *HTTPChallenge) CrawlURLsWithWorkerPool(urls []string) {
+// CrawlURLsWithWorkerPool crawls urls with -max-workers workers, without
+// following links, until done, stopped or ctx is done.
+func (hc *HTTPChallenge) CrawlURLsWithWorkerPool(ctx context.Context, urls []string) {
 	if hc.options.MaxWorkers <= 0 {
 		hc.options.MaxWorkers = 50 // Default to 50 workers
 	}
 
-	// Create channels for job distribution
-	urlChan := make(chan string, len(urls))
+	// Queue URLs, workers take the next one whose host is ready
+	for _, url := range UniqueStrings(urls) {
+		hc.scheduler.Push(url)
+	}
 	var wg sync.WaitGroup
 
 	// Start workers
@@ -472,22 +1059,19 @@ func (hc *HTTPChallenge) CrawlURLsWithWorkerPool(urls []string) {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			for url := range urlChan {
-				if hc.HasURL(url) {
-					continue
+			for {
+				url, ok := hc.scheduler.Next(ctx)
+				if !ok {
+					return
+				}
+				if !hc.emailLimitReached() && hc.claimURL(url, 0) {
+					hc.crawlSingleURL(ctx, url)
 				}
-				hc.AddURL(url)
-				hc.CrawlSingleURL(url)
+				hc.scheduler.Done(url)
 			}
 		}()
 	}
 
-	// Send URLs to workers
-	for _, url := range urls {
-		urlChan <- url
-	}
-	close(urlChan)
-
 	// Wait for all workers to complete
 	wg.Wait()
 }
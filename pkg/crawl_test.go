@@ -0,0 +1,232 @@
+package pkg
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testSitePages = 40
+
+// newTestSite serves pages /page/0 to /page/N-1, each with its own email
+// and links to the next few pages.
+func newTestSite(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", http.NotFound)
+	mux.HandleFunc("/page/", func(w http.ResponseWriter, r *http.Request) {
+		var n int
+		if _, err := fmt.Sscanf(r.URL.Path, "/page/%d", &n); err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if r.Method == http.MethodHead {
+			return
+		}
+		fmt.Fprintf(w, "<html><head><title>Page %d</title></head><body><p>contact: user%d@example.com</p>", n, n)
+		for i := 1; i <= 3; i++ {
+			fmt.Fprintf(w, `<a href="/page/%d">next</a>`, (n+i)%testSitePages)
+		}
+		fmt.Fprint(w, "</body></html>")
+	})
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func testSiteEmails() []string {
+	emails := []string{}
+	for i := 0; i < testSitePages; i++ {
+		emails = append(emails, fmt.Sprintf("user%d@example.com", i))
+	}
+	sort.Strings(emails)
+	return emails
+}
+
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	lines := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimSpace(scanner.Text()))
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+func newTestHTTPChallenge(ts *httptest.Server, out string, fromFile bool) *HTTPChallenge {
+	hc, err := NewHTTPChallenge(func(opt *CrawlOptions) error {
+		opt.URL = ts.URL + "/page/0"
+		opt.Depth = -1
+		opt.LimitUrls = 1000
+		opt.LimitEmails = 1000
+		opt.TimeoutMillisecond = 5000
+		opt.MaxWorkers = 8
+		opt.WriteToFile = out
+		opt.CrawlFromFile = fromFile
+		return nil
+	})
+	if err != nil {
+		// the options of tests are valid
+		panic(err)
+	}
+	return hc
+}
+
+func TestCrawlRecursiveParallel(t *testing.T) {
+	ts := newTestSite(t)
+	out := filepath.Join(t.TempDir(), "emails.txt")
+
+	hc := newTestHTTPChallenge(ts, out, false)
+	hc.CrawlRecursiveParallel(context.Background(), ts.URL+"/page/0")
+
+	if hc.TotalURLsCrawled != testSitePages {
+		t.Errorf("TotalURLsCrawled = %d, want %d", hc.TotalURLsCrawled, testSitePages)
+	}
+	emails := append([]string{}, hc.Emails...)
+	sort.Strings(emails)
+	if !IsEqualSlice(emails, testSiteEmails()) {
+		t.Errorf("Emails = %v, want %v", emails, testSiteEmails())
+	}
+	if lines := readLines(t, out); !IsEqualSlice(lines, testSiteEmails()) {
+		t.Errorf("output file = %v, want %v", lines, testSiteEmails())
+	}
+}
+
+func TestCrawlRecursiveParallelLimits(t *testing.T) {
+	ts := newTestSite(t)
+
+	hc := newTestHTTPChallenge(ts, "", false)
+	hc.options.LimitUrls = 10
+	hc.CrawlRecursiveParallel(context.Background(), ts.URL+"/page/0")
+
+	if hc.GetURLsCount() != 10 {
+		t.Errorf("GetURLsCount() = %d, want 10", hc.GetURLsCount())
+	}
+	if hc.TotalURLsCrawled > 10 {
+		t.Errorf("TotalURLsCrawled = %d, want at most 10", hc.TotalURLsCrawled)
+	}
+}
+
+func TestCrawlURLsWithWorkerPool(t *testing.T) {
+	ts := newTestSite(t)
+	out := filepath.Join(t.TempDir(), "emails.txt")
+
+	urls := []string{}
+	for i := 0; i < testSitePages; i++ {
+		// every url twice, duplicates are crawled once
+		urls = append(urls, fmt.Sprintf("%s/page/%d", ts.URL, i), fmt.Sprintf("%s/page/%d", ts.URL, i))
+	}
+	hc := newTestHTTPChallenge(ts, out, true)
+	hc.CrawlURLsWithWorkerPool(context.Background(), urls)
+
+	if hc.TotalURLsCrawled != testSitePages {
+		t.Errorf("TotalURLsCrawled = %d, want %d", hc.TotalURLsCrawled, testSitePages)
+	}
+	if lines := readLines(t, out); !IsEqualSlice(lines, testSiteEmails()) {
+		t.Errorf("output file = %v, want %v", lines, testSiteEmails())
+	}
+}
+
+func TestCrawlRecursiveParallelCancelled(t *testing.T) {
+	ts := newTestSite(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	hc := newTestHTTPChallenge(ts, "", false)
+	hc.CrawlRecursiveParallel(ctx, ts.URL+"/page/0")
+
+	if hc.TotalURLsCrawled != 0 {
+		t.Errorf("TotalURLsCrawled = %d after cancel, want 0", hc.TotalURLsCrawled)
+	}
+}
+
+func TestCrawlRecursiveParallelStopped(t *testing.T) {
+	var hc *HTTPChallenge
+	mu := sync.Mutex{}
+	requests := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.URL.Path)
+		mu.Unlock()
+		// stopped while / is crawled, which is still finished
+		hc.Stop()
+		time.Sleep(100 * time.Millisecond)
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body>page@acme.com <a href="/a">a</a> <a href="/b">b</a></body></html>`)
+	}))
+	t.Cleanup(ts.Close)
+
+	hc = newTestHTTPChallenge(ts, "", false)
+	hc.options.IgnoreRobots = true
+	hc.CrawlRecursiveParallel(context.Background(), ts.URL+"/")
+
+	if hc.TotalURLsCrawled != 1 || !IsEqualSlice(hc.Emails, []string{"page@acme.com"}) || len(hc.FailedURLs) != 0 {
+		t.Errorf("TotalURLsCrawled = %d, Emails = %v, FailedURLs = %v, want / crawled", hc.TotalURLsCrawled, hc.Emails, hc.FailedURLs)
+	}
+	if !IsEqualSlice(requests, []string{"/"}) {
+		t.Errorf("requested %v, want no url dispatched once stopped", requests)
+	}
+}
+
+func TestCrawlRecursiveRecords(t *testing.T) {
+	ts := newTestSite(t)
+	out := filepath.Join(t.TempDir(), "emails.jsonl")
+
+	hc := newTestHTTPChallenge(ts, "", false)
+	hc.options.MaxWorkers = 1
+	output, err := NewRecordWriter(out, FormatJSONL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hc.AddSink(output)
+	hc.CrawlRecursiveParallel(context.Background(), ts.URL+"/page/0")
+	if err := hc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	records := map[string]Record{}
+	for _, line := range readLines(t, out) {
+		var r Record
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatal(err)
+		}
+		records[r.Value] = r
+	}
+	if len(records) != testSitePages {
+		t.Fatalf("%d records, want %d", len(records), testSitePages)
+	}
+	// page n is first linked from page n-1, n-2 or n-3
+	r := records["user4@example.com"]
+	want := Record{
+		Type:         RecordEmail,
+		Value:        "user4@example.com",
+		SourceURL:    ts.URL + "/page/4",
+		PageTitle:    "Page 4",
+		Status:       200,
+		DiscoveredAt: r.DiscoveredAt,
+		Depth:        2,
+		Method:       MethodText,
+		Class:        ClassPlaceholder,
+		Confidence:   0.5,
+	}
+	if r != want {
+		t.Errorf("record = %+v, want %+v", r, want)
+	}
+}
@@ -0,0 +1,181 @@
+package pkg
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite" // registers the sqlite driver
+)
+
+const emailDBSchema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id          INTEGER PRIMARY KEY,
+	url         TEXT NOT NULL,
+	started_at  TIMESTAMP NOT NULL,
+	finished_at TIMESTAMP
+);
+CREATE TABLE IF NOT EXISTS domains (
+	id         INTEGER PRIMARY KEY,
+	name       TEXT NOT NULL UNIQUE,
+	first_seen TIMESTAMP NOT NULL,
+	last_seen  TIMESTAMP NOT NULL
+);
+CREATE TABLE IF NOT EXISTS emails (
+	id           INTEGER PRIMARY KEY,
+	address      TEXT NOT NULL UNIQUE,
+	domain_id    INTEGER NOT NULL REFERENCES domains(id),
+	first_seen   TIMESTAMP NOT NULL,
+	last_seen    TIMESTAMP NOT NULL,
+	first_run_id INTEGER NOT NULL REFERENCES runs(id),
+	last_run_id  INTEGER NOT NULL REFERENCES runs(id)
+);
+CREATE TABLE IF NOT EXISTS pages (
+	id         INTEGER PRIMARY KEY,
+	url        TEXT NOT NULL UNIQUE,
+	title      TEXT NOT NULL,
+	status     INTEGER NOT NULL,
+	first_seen TIMESTAMP NOT NULL,
+	last_seen  TIMESTAMP NOT NULL
+);
+CREATE TABLE IF NOT EXISTS email_pages (
+	email_id    INTEGER NOT NULL REFERENCES emails(id),
+	page_id     INTEGER NOT NULL REFERENCES pages(id),
+	method      TEXT NOT NULL,
+	depth       INTEGER NOT NULL,
+	first_seen  TIMESTAMP NOT NULL,
+	last_seen   TIMESTAMP NOT NULL,
+	last_run_id INTEGER NOT NULL REFERENCES runs(id),
+	PRIMARY KEY (email_id, page_id)
+);
+-- emails found for the first time by the latest run
+CREATE VIEW IF NOT EXISTS new_emails AS
+	SELECT address, first_seen FROM emails
+	WHERE first_run_id = (SELECT MAX(id) FROM runs);
+`
+
+// EmailDB stores the email records in a SQLite database across crawls. Each
+// crawl is a run, and emails, domains, pages and the pages an email was
+// found on are upserted, so a repeated crawl updates last_seen instead of
+// adding duplicates.
+type EmailDB struct {
+	db    *sql.DB
+	runID int64
+}
+
+// OpenEmailDB opens or creates the database at path, and starts a run for
+// the crawl of url.
+func OpenEmailDB(path, url string) (*EmailDB, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening database: %w", err)
+	}
+	// a single connection, as writes to sqlite are serialized anyway
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(emailDBSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error creating database schema: %w", err)
+	}
+	res, err := db.Exec(`INSERT INTO runs (url, started_at) VALUES (?, ?)`, url, time.Now().UTC())
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error starting run: %w", err)
+	}
+	runID, err := res.LastInsertId()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &EmailDB{db: db, runID: runID}, nil
+}
+
+func (d *EmailDB) Write(records []Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	// a no-op once committed
+	defer func() { _ = tx.Rollback() }()
+
+	for _, r := range records {
+		// only emails have tables
+		if r.Type != RecordEmail {
+			continue
+		}
+		if err := d.upsert(tx, r); err != nil {
+			return fmt.Errorf("error saving %s: %w", r.Value, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// WritePage writes the email records of a page, linking the emails found
+// before on other pages to this one too.
+func (d *EmailDB) WritePage(records []Record) error {
+	return d.Write(records)
+}
+
+func (d *EmailDB) upsert(tx *sql.Tx, r Record) error {
+	domain := ""
+	if i := strings.LastIndex(r.Value, "@"); i != -1 {
+		domain = strings.ToLower(r.Value[i+1:])
+	}
+	at := r.DiscoveredAt.UTC()
+
+	var domainID, emailID, pageID int64
+	err := tx.QueryRow(`INSERT INTO domains (name, first_seen, last_seen) VALUES (?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET last_seen = excluded.last_seen
+		RETURNING id`, domain, at, at).Scan(&domainID)
+	if err != nil {
+		return err
+	}
+	err = tx.QueryRow(`INSERT INTO emails (address, domain_id, first_seen, last_seen, first_run_id, last_run_id) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (address) DO UPDATE SET last_seen = excluded.last_seen, last_run_id = excluded.last_run_id
+		RETURNING id`, r.Value, domainID, at, at, d.runID, d.runID).Scan(&emailID)
+	if err != nil {
+		return err
+	}
+	err = tx.QueryRow(`INSERT INTO pages (url, title, status, first_seen, last_seen) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (url) DO UPDATE SET title = excluded.title, status = excluded.status, last_seen = excluded.last_seen
+		RETURNING id`, r.SourceURL, r.PageTitle, r.Status, at, at).Scan(&pageID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO email_pages (email_id, page_id, method, depth, first_seen, last_seen, last_run_id) VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (email_id, page_id) DO UPDATE SET method = excluded.method, depth = excluded.depth, last_seen = excluded.last_seen, last_run_id = excluded.last_run_id`,
+		emailID, pageID, r.Method, r.Depth, at, at, d.runID)
+	return err
+}
+
+// NewEmails returns the emails found for the first time by this run.
+func (d *EmailDB) NewEmails() ([]string, error) {
+	rows, err := d.db.Query(`SELECT address FROM emails WHERE first_run_id = ? ORDER BY address`, d.runID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	emails := []string{}
+	for rows.Next() {
+		var email string
+		if err := rows.Scan(&email); err != nil {
+			return nil, err
+		}
+		emails = append(emails, email)
+	}
+	return emails, rows.Err()
+}
+
+// Close finishes the run and closes the database.
+func (d *EmailDB) Close() error {
+	_, err := d.db.Exec(`UPDATE runs SET finished_at = ? WHERE id = ?`, time.Now().UTC(), d.runID)
+	if cerr := d.db.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,127 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEmailDBRuns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "emails.sqlite")
+	first := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	second := first.Add(7 * 24 * time.Hour)
+
+	run := func(at time.Time, emails ...string) []string {
+		t.Helper()
+		db, err := OpenEmailDB(path, "https://example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		records := []Record{}
+		for _, email := range emails {
+			records = append(records, Record{Type: RecordEmail, Value: email, SourceURL: "https://example.com/team", Status: 200, DiscoveredAt: at, Method: MethodText})
+		}
+		if err := db.Write(records); err != nil {
+			t.Fatal(err)
+		}
+		newEmails, err := db.NewEmails()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return newEmails
+	}
+
+	if got := run(first, "a@example.com", "b@example.com"); !IsEqualSlice(got, []string{"a@example.com", "b@example.com"}) {
+		t.Errorf("first run NewEmails() = %v", got)
+	}
+	if got := run(second, "b@example.com", "c@example.org"); !IsEqualSlice(got, []string{"c@example.org"}) {
+		t.Errorf("second run NewEmails() = %v, want [c@example.org]", got)
+	}
+
+	db, err := OpenEmailDB(path, "https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var emails, pages, emailPages, domains int
+	err = db.db.QueryRow(`SELECT
+		(SELECT COUNT(*) FROM emails), (SELECT COUNT(*) FROM pages),
+		(SELECT COUNT(*) FROM email_pages), (SELECT COUNT(*) FROM domains)`).Scan(&emails, &pages, &emailPages, &domains)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if emails != 3 || pages != 1 || emailPages != 3 || domains != 2 {
+		t.Errorf("counts = %d emails, %d pages, %d email_pages, %d domains, want 3, 1, 3, 2", emails, pages, emailPages, domains)
+	}
+
+	var firstSeen, lastSeen time.Time
+	err = db.db.QueryRow(`SELECT first_seen, last_seen FROM emails WHERE address = ?`, "b@example.com").Scan(&firstSeen, &lastSeen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !firstSeen.Equal(first) || !lastSeen.Equal(second) {
+		t.Errorf("b@example.com seen %v to %v, want %v to %v", firstSeen, lastSeen, first, second)
+	}
+
+	// an email on two pages of a crawl is linked to both
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body>shared@example.com <a href="/team">team</a></body></html>`)
+	}))
+	t.Cleanup(ts.Close)
+	hc := newTestHTTPChallenge(ts, "", false)
+	hc.options.URL = ts.URL
+	hc.AddSink(db)
+	hc.CrawlRecursiveParallel(context.Background(), ts.URL+"/")
+	var pageURLs []string
+	rows, err := db.db.Query(`SELECT p.url FROM email_pages ep JOIN pages p ON p.id = ep.page_id
+		JOIN emails e ON e.id = ep.email_id WHERE e.address = ? ORDER BY p.url`, "shared@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var u string
+		if err := rows.Scan(&u); err != nil {
+			t.Fatal(err)
+		}
+		pageURLs = append(pageURLs, u)
+	}
+	if want := []string{ts.URL + "/", ts.URL + "/team"}; !IsEqualSlice(pageURLs, want) {
+		t.Errorf("pages of shared@example.com = %v, want %v", pageURLs, want)
+	}
+}
+
+func TestEmailDBLimitEmails(t *testing.T) {
+	db, err := OpenEmailDB(filepath.Join(t.TempDir(), "emails.sqlite"), "https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body>first@example.com second@example.com</body></html>`)
+	}))
+	t.Cleanup(ts.Close)
+	hc := newTestHTTPChallenge(ts, "", false)
+	hc.options.LimitEmails = 1
+	hc.AddSink(db)
+	hc.CrawlRecursiveParallel(context.Background(), ts.URL+"/")
+
+	var emails, emailPages int
+	err = db.db.QueryRow(`SELECT (SELECT COUNT(*) FROM emails), (SELECT COUNT(*) FROM email_pages)`).Scan(&emails, &emailPages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if emails != 1 || emailPages != 1 || !IsEqualSlice(hc.Emails, []string{"first@example.com"}) {
+		t.Errorf("%d emails on %d pages, Emails = %v, want first@example.com only", emails, emailPages, hc.Emails)
+	}
+}
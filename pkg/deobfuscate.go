@@ -0,0 +1,130 @@
+package pkg
+
+import (
+	"encoding/hex"
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Deobfuscator decodes the addresses a page hides from plain text
+// extraction.
+type Deobfuscator struct {
+	Name   string
+	Decode func(html string) []string
+}
+
+// Deobfuscators are the available deobfuscators, all enabled by default.
+var Deobfuscators = []Deobfuscator{
+	{Name: "cloudflare", Decode: DecodeCloudflareEmails},
+	{Name: "entities", Decode: DecodeEntityEmails},
+	{Name: "urlencoded", Decode: DecodeURLEncodedEmails},
+	{Name: "textual", Decode: DecodeTextualEmails},
+}
+
+// DeobfuscatorNames returns the names of Deobfuscators.
+func DeobfuscatorNames() []string {
+	names := []string{}
+	for _, d := range Deobfuscators {
+		names = append(names, d.Name)
+	}
+	return names
+}
+
+// ParseDeobfuscators returns the deobfuscators of names, in the order of
+// Deobfuscators.
+func ParseDeobfuscators(names []string) ([]Deobfuscator, error) {
+	for _, name := range names {
+		if !StringInSlice(name, DeobfuscatorNames()) {
+			return nil, fmt.Errorf("unknown deobfuscator %q, use some of %v", name, DeobfuscatorNames())
+		}
+	}
+	deobfuscators := []Deobfuscator{}
+	for _, d := range Deobfuscators {
+		if StringInSlice(d.Name, names) {
+			deobfuscators = append(deobfuscators, d)
+		}
+	}
+	return deobfuscators, nil
+}
+
+var (
+	// plainEmailRegexp matches candidates, with utf-8 local parts and
+	// domains, validated by NormalizeEmail
+	plainEmailRegexp = regexp.MustCompile(`[\p{L}\p{M}\p{N}._+-]+@[\p{L}\p{M}\p{N}-]+(?:\.[\p{L}\p{M}\p{N}-]+)+`)
+	// escapeRegexp matches the escapes of scripts and json, which glue the
+	// letters they escape to addresses, like \u003cjohn@example.com\u003e
+	escapeRegexp = regexp.MustCompile(`\\(?:u[0-9a-fA-F]{4}|x[0-9a-fA-F]{2}|[nrt])`)
+
+	cloudflareEmailRegexp = regexp.MustCompile(`(?:data-cfemail=["']?|/cdn-cgi/l/email-protection#)([0-9a-fA-F]+)`)
+	percentRegexp         = regexp.MustCompile(`%[0-9a-fA-F]{2}`)
+
+	// the @ and . of name AT domain DOT com, name [at] domain (dot) com and
+	// name @ domain . com
+	textualAt     = `(?:\s*[\[({<]\s*(?i:at)\s*[\])}>]\s*|\s+AT\s+|\s*@\s*)`
+	textualDot    = `(?:\s*[\[({<]\s*(?i:dot)\s*[\])}>]\s*|\s+DOT\s+|\s+\.\s+|\.)`
+	textualRegexp = regexp.MustCompile(`[a-zA-Z0-9._%+-]+` + textualAt + `[a-zA-Z0-9-]+(?:` + textualDot + `[a-zA-Z0-9-]+)*` + textualDot + `[a-zA-Z]{2,}\b`)
+	textualAtRe   = regexp.MustCompile(textualAt)
+	textualDotRe  = regexp.MustCompile(textualDot)
+)
+
+// DecodeCloudflareEmails decodes the addresses hidden by Cloudflare email
+// protection, in data-cfemail attributes and /cdn-cgi/l/email-protection#
+// links: hex, with the first byte the xor key of the others.
+func DecodeCloudflareEmails(text string) []string {
+	emails := []string{}
+	for _, m := range cloudflareEmailRegexp.FindAllStringSubmatch(text, -1) {
+		b, err := hex.DecodeString(m[1])
+		if err != nil || len(b) < 2 {
+			continue
+		}
+		for i := 1; i < len(b); i++ {
+			b[i] ^= b[0]
+		}
+		if email := string(b[1:]); emailRegexp.MatchString(email) {
+			emails = append(emails, email)
+		}
+	}
+	return emails
+}
+
+// DecodeEntityEmails finds the addresses written with html entities, like
+// john&#64;example&#x2E;com or john&commat;example.com.
+func DecodeEntityEmails(text string) []string {
+	if !strings.Contains(text, "&") {
+		return []string{}
+	}
+	return plainEmailRegexp.FindAllString(html.UnescapeString(text), -1)
+}
+
+// DecodeURLEncodedEmails finds the addresses written url encoded, like
+// john%40example.com.
+func DecodeURLEncodedEmails(text string) []string {
+	if !strings.Contains(text, "%") {
+		return []string{}
+	}
+	decoded := percentRegexp.ReplaceAllStringFunc(text, func(s string) string {
+		unescaped, err := url.PathUnescape(s)
+		if err != nil {
+			return s
+		}
+		return unescaped
+	})
+	return plainEmailRegexp.FindAllString(decoded, -1)
+}
+
+// DecodeTextualEmails finds the addresses spelled out, like
+// name AT domain DOT com, name [at] domain (dot) com or name @ domain . com.
+func DecodeTextualEmails(text string) []string {
+	emails := []string{}
+	for _, m := range textualRegexp.FindAllString(text, -1) {
+		email := textualAtRe.ReplaceAllString(m, "@")
+		email = textualDotRe.ReplaceAllString(email, ".")
+		if emailRegexp.MatchString(email) {
+			emails = append(emails, email)
+		}
+	}
+	return emails
+}
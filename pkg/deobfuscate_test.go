@@ -0,0 +1,75 @@
+package pkg
+
+import (
+	"testing"
+)
+
+func TestDeobfuscators(t *testing.T) {
+	tests := []struct {
+		name     string
+		decode   func(string) []string
+		text     string
+		expected []string
+	}{
+		// "john@example.com" xor 0x42
+		{"cloudflare attribute", DecodeCloudflareEmails, `<span class="__cf_email__" data-cfemail="42282d2a2c02273a232f322e276c212d2f">[email&#160;protected]</span>`, []string{"john@example.com"}},
+		{"cloudflare link", DecodeCloudflareEmails, `<a href="/cdn-cgi/l/email-protection#42282d2a2c02273a232f322e276c212d2f">Email</a>`, []string{"john@example.com"}},
+		{"cloudflare garbage", DecodeCloudflareEmails, `<span data-cfemail="42">x</span><span data-cfemail="4243">y</span>`, []string{}},
+		{"decimal entities", DecodeEntityEmails, `john&#64;example&#46;com`, []string{"john@example.com"}},
+		{"hex entities", DecodeEntityEmails, `&#x6a;ohn&#x40;example.com`, []string{"john@example.com"}},
+		{"named entities", DecodeEntityEmails, `john&commat;example&period;com`, []string{"john@example.com"}},
+		{"no entities", DecodeEntityEmails, `john at example`, []string{}},
+		{"url encoded", DecodeURLEncodedEmails, `/contact?email=john%40example.com&x=%zz`, []string{"john@example.com"}},
+		{"textual uppercase", DecodeTextualEmails, `write to john AT example DOT com today`, []string{"john@example.com"}},
+		{"textual brackets", DecodeTextualEmails, `john.doe [at] mail (dot) example {dot} org`, []string{"john.doe@mail.example.org"}},
+		{"textual spaced", DecodeTextualEmails, `john @ example . com`, []string{"john@example.com"}},
+		{"textual prose", DecodeTextualEmails, `meet me at the station dot later`, []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.decode(test.text)
+			if len(got) == 0 && len(test.expected) == 0 {
+				return
+			}
+			if !IsEqualSlice(got, test.expected) {
+				t.Errorf("decode(%q) = %v, want %v", test.text, got, test.expected)
+			}
+		})
+	}
+}
+
+func TestParseDeobfuscators(t *testing.T) {
+	deobfuscators, err := ParseDeobfuscators([]string{"textual", "cloudflare"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deobfuscators) != 2 || deobfuscators[0].Name != "cloudflare" || deobfuscators[1].Name != "textual" {
+		t.Errorf("ParseDeobfuscators() = %v", deobfuscators)
+	}
+	if _, err := ParseDeobfuscators([]string{"rot13"}); err == nil {
+		t.Error("ParseDeobfuscators() = nil error for rot13")
+	}
+}
+
+func TestExtractEmailMatchesFromText(t *testing.T) {
+	text := `plain@example.com, hidden&#64;example.com, also plain@example.com`
+	deobfuscators, err := ParseDeobfuscators([]string{"entities"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	matches := ExtractEmailMatchesFromText(text, deobfuscators)
+	methods := map[string]string{}
+	for _, m := range matches {
+		if _, ok := methods[m.Email]; !ok {
+			methods[m.Email] = m.Method
+		}
+	}
+	if methods["plain@example.com"] != MethodText || methods["hidden@example.com"] != MethodDeobfuscated {
+		t.Errorf("methods = %v", methods)
+	}
+
+	if matches := ExtractEmailMatchesFromText(text, nil); len(matches) != 2 {
+		t.Errorf("ExtractEmailMatchesFromText() without deobfuscators = %v", matches)
+	}
+}
@@ -0,0 +1,197 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// Document is the text of a downloaded document, with its title and the
+// urls of its links, like mailto ones.
+type Document struct {
+	Title string
+	Text  string
+	Links []string
+	// contacts are the ones listed by formats like vcards and feeds
+	contacts []contactItem
+}
+
+// DocumentFormat reads the text of the documents of some media types or
+// file extensions.
+type DocumentFormat struct {
+	Name       string
+	MediaTypes []string
+	Extensions []string
+	Read       func(data []byte) (*Document, error)
+}
+
+// DocumentFormats are the formats of documents -documents can crawl, none
+// by default.
+var DocumentFormats = []DocumentFormat{
+	{Name: "pdf", MediaTypes: []string{"application/pdf", "application/x-pdf"}, Extensions: []string{".pdf"}, Read: ReadPDF},
+	{Name: "office", MediaTypes: []string{
+		"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		"application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	}, Extensions: []string{".docx", ".docm", ".xlsx", ".xlsm", ".pptx", ".pptm"}, Read: ReadOffice},
+	{Name: "odf", MediaTypes: []string{
+		"application/vnd.oasis.opendocument.text",
+		"application/vnd.oasis.opendocument.spreadsheet",
+		"application/vnd.oasis.opendocument.presentation",
+	}, Extensions: []string{".odt", ".ods", ".odp"}, Read: ReadODF},
+}
+
+// DocumentFormatNames returns the names of DocumentFormats.
+func DocumentFormatNames() []string {
+	names := []string{}
+	for _, d := range DocumentFormats {
+		names = append(names, d.Name)
+	}
+	return names
+}
+
+// ParseDocumentFormats returns the document formats of names, in the order
+// of DocumentFormats.
+func ParseDocumentFormats(names []string) ([]DocumentFormat, error) {
+	for _, name := range names {
+		if !StringInSlice(name, DocumentFormatNames()) {
+			return nil, fmt.Errorf("unknown document format %q, use some of %v", name, DocumentFormatNames())
+		}
+	}
+	formats := []DocumentFormat{}
+	for _, d := range DocumentFormats {
+		if StringInSlice(d.Name, names) {
+			formats = append(formats, d)
+		}
+	}
+	return formats, nil
+}
+
+// documentFormatOf returns the format of formats of a document at rawURL
+// served as contentType, by its media type or, when served as anything,
+// by the extension of rawURL.
+func documentFormatOf(formats []DocumentFormat, contentType, rawURL string) (DocumentFormat, bool) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	for _, d := range formats {
+		if StringInSlice(mediaType, d.MediaTypes) {
+			return d, true
+		}
+	}
+	if mediaType != "" && mediaType != "application/octet-stream" && mediaType != "binary/octet-stream" {
+		return DocumentFormat{}, false
+	}
+	return documentFormatByExtension(formats, rawURL)
+}
+
+func documentFormatByExtension(formats []DocumentFormat, rawURL string) (DocumentFormat, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return DocumentFormat{}, false
+	}
+	ext := strings.ToLower(path.Ext(u.Path))
+	for _, d := range formats {
+		if StringInSlice(ext, d.Extensions) {
+			return d, true
+		}
+	}
+	return DocumentFormat{}, false
+}
+
+// ReadPDF returns the text of the pages of a pdf, and of its annotations,
+// with the uris of its links.
+func ReadPDF(data []byte) (doc *Document, err error) {
+	// the pdf reader panics on malformed files
+	defer func() {
+		if r := recover(); r != nil {
+			doc, err = nil, fmt.Errorf("error reading pdf: %v", r)
+		}
+	}()
+	r, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("error reading pdf: %w", err)
+	}
+
+	doc = &Document{Title: r.Trailer().Key("Info").Key("Title").Text()}
+	text := strings.Builder{}
+	for i := 1; i <= r.NumPage(); i++ {
+		page := r.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+		text.WriteString(pdfPageText(page))
+		text.WriteString("\n")
+
+		annots := page.V.Key("Annots")
+		for j := 0; j < annots.Len(); j++ {
+			annot := annots.Index(j)
+			if contents := annot.Key("Contents").Text(); contents != "" {
+				text.WriteString(contents)
+				text.WriteString("\n")
+			}
+			if uri := annot.Key("A").Key("URI").RawString(); uri != "" {
+				doc.Links = append(doc.Links, uri)
+			}
+		}
+	}
+	doc.Text = text.String()
+	return doc, nil
+}
+
+// pdfPageText returns the text shown by the content stream of page, with a
+// line break at each move of the text position, so the lines of a page do
+// not run into each other, and a space at each wide gap within a line.
+func pdfPageText(page pdf.Page) string {
+	fonts := map[string]pdf.Font{}
+	for _, name := range page.Fonts() {
+		fonts[name] = page.Font(name)
+	}
+	var enc pdf.TextEncoding
+	text := strings.Builder{}
+	show := func(s string) {
+		if enc != nil {
+			s = enc.Decode(s)
+		}
+		text.WriteString(s)
+	}
+
+	pdf.Interpret(page.V.Key("Contents"), func(stk *pdf.Stack, op string) {
+		args := make([]pdf.Value, stk.Len())
+		for i := len(args) - 1; i >= 0; i-- {
+			args[i] = stk.Pop()
+		}
+		switch op {
+		case "Td", "TD", "Tm", "T*", "ET", "'", "\"":
+			text.WriteString("\n")
+		}
+		if len(args) == 0 {
+			return
+		}
+		switch op {
+		case "Tf":
+			enc = nil
+			if font, ok := fonts[args[0].Name()]; ok {
+				enc = font.Encoder()
+			}
+		case "Tj", "'", "\"":
+			show(args[len(args)-1].RawString())
+		case "TJ":
+			for i := 0; i < args[0].Len(); i++ {
+				switch x := args[0].Index(i); x.Kind() {
+				case pdf.String:
+					show(x.RawString())
+				case pdf.Integer, pdf.Real:
+					// in thousandths of the font size, negative moves right
+					if x.Float64() < -200 {
+						text.WriteString(" ")
+					}
+				}
+			}
+		}
+	})
+	return text.String()
+}
@@ -0,0 +1,153 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testPDF returns a one page pdf titled title, showing content, with a link
+// to uri and a note of note.
+func testPDF(title, content, uri, note string) []byte {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> /Annots [6 0 R 7 0 R] >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content)+1, content),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		fmt.Sprintf("<< /Type /Annot /Subtype /Link /Rect [72 600 200 620] /A << /S /URI /URI (%s) >> >>", uri),
+		fmt.Sprintf("<< /Type /Annot /Subtype /Text /Rect [72 500 90 520] /Contents (%s) >>", note),
+		fmt.Sprintf("<< /Title (%s) >>", title),
+	}
+	b := bytes.Buffer{}
+	b.WriteString("%PDF-1.4\n")
+	offsets := []int{}
+	for i, o := range objects {
+		offsets = append(offsets, b.Len())
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, o)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, len(objects), xref)
+	return b.Bytes()
+}
+
+var testReport = testPDF(
+	"Annual Report",
+	"BT /F1 12 Tf 72 720 Td (Contact: jane.roe@acme.com) Tj 0 -14 Td (Phone desk) Tj 0 -14 Td [(Sales)-300(sales@acme.com)] TJ ET",
+	"mailto:press@acme.com?subject=Report",
+	"Questions to notes@acme.com",
+)
+
+func TestReadPDF(t *testing.T) {
+	doc, err := ReadPDF(testReport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Title != "Annual Report" {
+		t.Errorf("Title = %q, want %q", doc.Title, "Annual Report")
+	}
+	for _, line := range []string{"Contact: jane.roe@acme.com\n", "Phone desk", "Sales sales@acme.com", "Questions to notes@acme.com"} {
+		if !strings.Contains(doc.Text, line) {
+			t.Errorf("Text = %q, want it to have %q", doc.Text, line)
+		}
+	}
+	if len(doc.Links) != 1 || doc.Links[0] != "mailto:press@acme.com?subject=Report" {
+		t.Errorf("Links = %v, want the mailto link", doc.Links)
+	}
+
+	if _, err := ReadPDF([]byte("%PDF-1.4\nnot really")); err == nil {
+		t.Error("ReadPDF() = nil error for a malformed pdf")
+	}
+}
+
+func TestCrawlDocuments(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><body><a href="/report.pdf">report</a> <a href="/download?id=2">brochure</a> <a href="/big.pdf">big</a> <a href="/roster.docx">roster</a></body></html>`))
+	})
+	mux.HandleFunc("/report.pdf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		_, _ = w.Write(testReport)
+	})
+	mux.HandleFunc("/download", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		_, _ = w.Write(testPDF("Brochure", "BT /F1 12 Tf 72 720 Td (brochure@acme.com) Tj ET", "https://acme.com", ""))
+	})
+	mux.HandleFunc("/big.pdf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		_, _ = w.Write(testPDF("Big", "BT /F1 12 Tf 72 720 Td ("+strings.Repeat("big@acme.com ", 1000)+") Tj ET", "", ""))
+	})
+	mux.HandleFunc("/roster.docx", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write(testZip(t, map[string]string{"word/document.xml": `<w:document><w:body><w:p><w:r><w:t>roster@acme.com</w:t></w:r></w:p></w:body></w:document>`}))
+	})
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+
+	crawl := func(documents []string) map[string]Record {
+		hc := newTestHTTPChallenge(ts, "", false)
+		hc.options.URL = ts.URL
+		hc.options.MaxDocumentBytes = 4096
+		hc.options.SnippetWindow = 20
+		extractors, err := NewExtractors(hc.options.Extract, hc.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hc.extractors = extractors
+		formats, err := ParseDocumentFormats(documents)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hc.documents = formats
+		records := &recordSink{}
+		hc.AddSink(records)
+		hc.CrawlRecursiveParallel(context.Background(), ts.URL)
+
+		got := map[string]Record{}
+		for _, r := range records.Records() {
+			got[r.Value] = r
+		}
+		if documents != nil && (len(hc.SkippedURLs) != 1 || hc.SkippedURLs[0].URL != ts.URL+"/big.pdf") {
+			t.Errorf("SkippedURLs = %v, want %s", hc.SkippedURLs, ts.URL+"/big.pdf")
+		}
+		return got
+	}
+
+	if got := crawl(nil); len(got) != 0 {
+		t.Errorf("records without -documents = %v, want none", got)
+	}
+
+	got := crawl([]string{"pdf", "office"})
+	want := map[string]string{
+		"jane.roe@acme.com": MethodText,
+		"sales@acme.com":    MethodText,
+		"notes@acme.com":    MethodText,
+		"press@acme.com":    MethodMailto,
+		"brochure@acme.com": MethodText,
+		"roster@acme.com":   MethodText,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("records = %v, want %v", got, want)
+	}
+	for email, method := range want {
+		r := got[email]
+		if r.Method != method {
+			t.Errorf("%s method = %q, want %q", email, r.Method, method)
+		}
+		if !strings.HasPrefix(r.SourceURL, ts.URL+"/") || r.SourceURL == ts.URL+"/" {
+			t.Errorf("%s source = %q, want the document", email, r.SourceURL)
+		}
+	}
+	if r := got["jane.roe@acme.com"]; r.PageTitle != "Annual Report" || r.Snippet == "" {
+		t.Errorf("jane.roe@acme.com = %+v, want the title and a snippet of the pdf", r)
+	}
+}
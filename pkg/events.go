@@ -0,0 +1,82 @@
+package pkg
+
+import (
+	"sync"
+	"time"
+)
+
+// Types of an Event
+const (
+	EventPageStarted = "page_started"
+	EventPageFetched = "page_fetched"
+	EventEmailFound  = "email_found"
+	EventError       = "error"
+	EventProgress    = "progress"
+	EventCompleted   = "completed"
+)
+
+// Event is something that happened during a crawl, streamed to the
+// clients of a job.
+type Event struct {
+	ID   int64     `json:"id"`
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	URL  string    `json:"url,omitempty"`
+	// Status is the HTTP status of page_fetched
+	Status int         `json:"status,omitempty"`
+	Email  *Record     `json:"email,omitempty"`
+	Error  string      `json:"error,omitempty"`
+	Stats  *CrawlStats `json:"stats,omitempty"`
+	// JobStatus is the final status of the job for completed
+	JobStatus string `json:"job_status,omitempty"`
+}
+
+// EventLog keeps the events of a job in order, so a client reconnecting
+// with the id of the last event it got misses none.
+type EventLog struct {
+	mu      sync.Mutex
+	events  []Event
+	changed chan struct{}
+	closed  bool
+}
+
+func NewEventLog() *EventLog {
+	return &EventLog{changed: make(chan struct{})}
+}
+
+// Append adds e with the next id. Events appended once closed are dropped.
+func (l *EventLog) Append(e Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return
+	}
+	e.ID = int64(len(l.events)) + 1
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	l.events = append(l.events, e)
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// Since returns the events after the one with id lastID, a channel closed
+// once there are more, and whether no more will come.
+func (l *EventLog) Since(lastID int64) ([]Event, <-chan struct{}, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lastID = min(max(lastID, 0), int64(len(l.events)))
+	events := append([]Event{}, l.events[lastID:]...)
+	return events, l.changed, l.closed
+}
+
+// Close marks the log complete, waking up everyone waiting on it.
+func (l *EventLog) Close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return
+	}
+	l.closed = true
+	close(l.changed)
+}
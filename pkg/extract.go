@@ -1,8 +1,328 @@
 package pkg
 
-type Extract struct {
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Page is a fetched page, as given to extractors.
+type Page struct {
+	URL    string
+	Status int
+	Header http.Header
+	Title  string
+	Body   string
+	// DOM is the parsed html, nil for other content
+	DOM *goquery.Selection
+	// Links are the link urls of documents without a dom, like pdfs
+	Links []string
+	// contacts are the ones listed by content like vcards and calendars
+	contacts []contactItem
+}
+
+// Finding is something an extractor found on a page, a value of one of the
+// record types, the method it was found with and its context on the page.
+type Finding struct {
+	Type   string
+	Value  string
+	Method string
+	Context
+}
+
+// Extractor finds one or more types of records on pages. Extract is called
+// from the crawling goroutines.
+type Extractor interface {
+	Extract(page *Page) []Finding
+}
+
+// ExtractorFactory returns an extractor configured by the crawl options.
+type ExtractorFactory func(opt *CrawlOptions) (Extractor, error)
+
+type registeredExtractor struct {
+	name    string
+	factory ExtractorFactory
+}
+
+var extractors = []registeredExtractor{
+	{"emails", newEmailExtractor},
+	{"phones", newPhoneExtractor},
+	{"socials", newSocialExtractor},
+	{"contacts", newContactFormExtractor},
+	{"structured", newStructuredExtractor},
+}
+
+// RegisterExtractor makes an extractor available to -extract under name.
+func RegisterExtractor(name string, factory ExtractorFactory) {
+	extractors = append(extractors, registeredExtractor{name, factory})
+}
+
+// ExtractorNames returns the names of the registered extractors.
+func ExtractorNames() []string {
+	names := []string{}
+	for _, e := range extractors {
+		names = append(names, e.name)
+	}
+	return names
+}
+
+// NewExtractors returns the extractors of names, in the order they were
+// registered.
+func NewExtractors(names []string, opt *CrawlOptions) ([]Extractor, error) {
+	for _, name := range names {
+		if !StringInSlice(name, ExtractorNames()) {
+			return nil, fmt.Errorf("unknown extractor %q, use some of %v", name, ExtractorNames())
+		}
+	}
+	result := []Extractor{}
+	for _, e := range extractors {
+		if !StringInSlice(e.name, names) {
+			continue
+		}
+		extractor, err := e.factory(opt)
+		if err != nil {
+			return nil, fmt.Errorf("error creating extractor %s: %w", e.name, err)
+		}
+		result = append(result, extractor)
+	}
+	return result, nil
+}
+
+type emailExtractor struct {
+	deobfuscators []Deobfuscator
+	window        int
+}
+
+func newEmailExtractor(opt *CrawlOptions) (Extractor, error) {
+	deobfuscators, err := ParseDeobfuscators(opt.Deobfuscate)
+	if err != nil {
+		return nil, err
+	}
+	return &emailExtractor{deobfuscators: deobfuscators, window: opt.SnippetWindow}, nil
+}
+
+func (e *emailExtractor) Extract(page *Page) []Finding {
+	// mailto links are read from the dom as their hrefs may be url encoded
+	matches := []EmailMatch{}
+	body := page.Body
+	if page.DOM != nil {
+		matches = ExtractEmailMatchesFromMailtos(page.DOM)
+		body = StripMailtoHrefs(body)
+	}
+	for _, link := range page.Links {
+		if strings.HasPrefix(strings.ToLower(link), "mailto:") {
+			for _, email := range ParseMailto(link) {
+				matches = append(matches, EmailMatch{Email: email, Method: MethodMailto})
+			}
+		}
+	}
+	matches = append(matches, ExtractEmailMatchesFromText(body, e.deobfuscators)...)
+
+	findings := []Finding{}
+	candidates := map[string]bool{}
+	emails := map[string]bool{}
+	names := map[string]string{}
+	if page.DOM != nil {
+		names = JSONLDNames(page.DOM)
+	}
+	for _, m := range matches {
+		if candidates[m.Email] {
+			continue
+		}
+		candidates[m.Email] = true
+		email, err := NormalizeEmail(m.Email)
+		if err != nil {
+			Logger().WithField("url", page.URL).Debug(err)
+			continue
+		}
+		if !emails[email] {
+			emails[email] = true
+			f := Finding{Type: RecordEmail, Value: email, Method: m.Method}
+			if page.DOM != nil {
+				f.Context = e.context(page.DOM, m)
+			} else if e.window > 0 && m.Method == MethodText {
+				f.Snippet = snippet(collapseSpace(body), m.Email, e.window)
+			}
+			if name := names[strings.ToLower(email)]; name != "" {
+				f.Name = name
+			}
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
+
+// context returns the context of the mailto link of m, or of the text with
+// it.
+func (e *emailExtractor) context(dom *goquery.Selection, m EmailMatch) Context {
+	if m.Method == MethodMailto {
+		var anchor *goquery.Selection
+		dom.Find("a[href], area[href]").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+			href, _ := s.Attr("href")
+			if StringInSlice(m.Email, ParseMailto(href)) {
+				anchor = s
+				return false
+			}
+			return true
+		})
+		if anchor != nil {
+			return ElementContext(anchor, m.Email, e.window)
+		}
+	}
+	return TextContext(dom, m.Email, e.window)
+}
+
+var phoneRegexp = regexp.MustCompile(`\+\d[\d\s().-]{6,20}\d`)
+
+type phoneExtractor struct {
+	window int
+}
+
+func newPhoneExtractor(opt *CrawlOptions) (Extractor, error) {
+	return phoneExtractor{window: opt.SnippetWindow}, nil
+}
+
+// Extract finds the numbers of tel links, and the international numbers,
+// starting with +, in the text of the page.
+func (e phoneExtractor) Extract(page *Page) []Finding {
+	findings := []Finding{}
+	text := page.Body
+	if page.DOM != nil {
+		page.DOM.Find("a[href]").Each(func(_ int, s *goquery.Selection) {
+			href, _ := s.Attr("href")
+			if len(href) > 4 && strings.EqualFold(href[:4], "tel:") {
+				if number := NormalizePhone(href[4:]); number != "" {
+					findings = append(findings, Finding{Type: RecordPhone, Value: number, Method: MethodTel, Context: ElementContext(s, s.Text(), e.window)})
+				}
+			}
+		})
+		text = page.DOM.Find("body").Text()
+	}
+	for _, m := range phoneRegexp.FindAllString(text, -1) {
+		if number := NormalizePhone(m); number != "" {
+			f := Finding{Type: RecordPhone, Value: number, Method: MethodText}
+			if page.DOM != nil {
+				f.Context = TextContext(page.DOM, m, e.window)
+			}
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
+
+// NormalizePhone returns number as + and digits, or "" if it does not have
+// the 7 to 15 digits of a phone number.
+func NormalizePhone(number string) string {
+	if decoded, err := url.PathUnescape(number); err == nil {
+		number = decoded
+	}
+	number, _, _ = strings.Cut(number, "?")
+	number = strings.TrimSpace(number)
+
+	digits := strings.Builder{}
+	for _, c := range number {
+		if c >= '0' && c <= '9' {
+			digits.WriteRune(c)
+		}
+	}
+	if digits.Len() < 7 || digits.Len() > 15 {
+		return ""
+	}
+	if strings.HasPrefix(number, "+") {
+		return "+" + digits.String()
+	}
+	return digits.String()
+}
+
+// socialHosts are the hosts of social profiles, and the paths of theirs
+// that are not profiles.
+var socialHosts = map[string][]string{
+	"facebook.com":  {"/sharer", "/share", "/dialog", "/plugins"},
+	"twitter.com":   {"/intent", "/share", "/home", "/search"},
+	"x.com":         {"/intent", "/share", "/home", "/search"},
+	"linkedin.com":  {"/shareArticle", "/share", "/sharing"},
+	"instagram.com": {"/p/", "/explore"},
+	"youtube.com":   {"/watch", "/embed", "/results"},
+	"github.com":    {},
+	"tiktok.com":    {"/embed"},
+	"pinterest.com": {"/pin/create"},
+}
+
+type socialExtractor struct {
+	window int
+}
+
+func newSocialExtractor(opt *CrawlOptions) (Extractor, error) {
+	return socialExtractor{window: opt.SnippetWindow}, nil
+}
+
+// Extract finds the links to social profiles, leaving out share links.
+func (e socialExtractor) Extract(page *Page) []Finding {
+	findings := []Finding{}
+	if page.DOM == nil {
+		return findings
+	}
+	page.DOM.Find("a[href]").Each(func(_ int, s *goquery.Selection) {
+		href, _ := s.Attr("href")
+		if profile, ok := socialProfile(href); ok {
+			findings = append(findings, Finding{Type: RecordSocial, Value: profile, Method: MethodLink, Context: ElementContext(s, s.Text(), e.window)})
+		}
+	})
+	return findings
+}
+
+func socialProfile(href string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(href))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", false
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	host = strings.TrimPrefix(host, "m.")
+	excluded, ok := socialHosts[host]
+	if !ok || strings.Trim(u.Path, "/") == "" {
+		return "", false
+	}
+	for _, prefix := range excluded {
+		if strings.HasPrefix(u.Path, prefix) {
+			return "", false
+		}
+	}
+	return "https://" + host + strings.TrimSuffix(u.Path, "/"), true
+}
+
+type contactFormExtractor struct {
+	window int
+}
+
+func newContactFormExtractor(opt *CrawlOptions) (Extractor, error) {
+	return contactFormExtractor{window: opt.SnippetWindow}, nil
 }
 
-func NewExtract() *Extract {
-	return &Extract{}
+// Extract finds the forms taking a message and an email address, and
+// returns the urls they post to.
+func (e contactFormExtractor) Extract(page *Page) []Finding {
+	findings := []Finding{}
+	if page.DOM == nil {
+		return findings
+	}
+	page.DOM.Find("form").Each(func(_ int, s *goquery.Selection) {
+		hasEmail := s.Find(`input[type="email"], input[name*="email" i]`).Length() > 0
+		hasMessage := s.Find("textarea").Length() > 0
+		if !hasEmail || !hasMessage {
+			return
+		}
+		action, _ := s.Attr("action")
+		if action = strings.TrimSpace(action); action == "" {
+			action = page.URL
+		}
+		action = RelativeToAbsoluteURL(action, page.URL, GetBaseURL(page.URL))
+		if action != "" {
+			findings = append(findings, Finding{Type: RecordContactForm, Value: action, Method: MethodForm, Context: ElementContext(s, "", e.window)})
+		}
+	})
+	return findings
 }
@@ -0,0 +1,100 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const testContactPage = `<html><body>
+<h1>Contact</h1>
+<p>Write to info@example.com or <a href="mailto:sales%40example.com?subject=Hi">sales</a>.</p>
+<p>Call <a href="tel:+1-555-010-9999">us</a> or +44 20 7946 0958, order 1234567 ships soon.</p>
+<a href="https://twitter.com/example">Twitter</a>
+<a href="https://twitter.com/intent/tweet?text=hi">Share</a>
+<a href="https://www.linkedin.com/company/example/">LinkedIn</a>
+<a href="https://github.com/">GitHub</a>
+<form action="/contact/send" method="post">
+  <input type="text" name="name"><input type="text" name="your-email"><textarea name="message"></textarea>
+</form>
+<form action="/search"><input type="search" name="q"></form>
+</body></html>`
+
+func testPage(t *testing.T) *Page {
+	t.Helper()
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(testContactPage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Page{URL: "https://example.com/contact", Status: 200, Body: testContactPage, DOM: doc.Selection}
+}
+
+func TestExtractors(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected []Finding
+	}{
+		{"emails", []Finding{
+			{Type: RecordEmail, Value: "sales@example.com", Method: MethodMailto},
+			{Type: RecordEmail, Value: "info@example.com", Method: MethodText},
+		}},
+		{"phones", []Finding{
+			{Type: RecordPhone, Value: "+15550109999", Method: MethodTel},
+			{Type: RecordPhone, Value: "+442079460958", Method: MethodText},
+		}},
+		{"socials", []Finding{
+			{Type: RecordSocial, Value: "https://twitter.com/example", Method: MethodLink},
+			{Type: RecordSocial, Value: "https://linkedin.com/company/example", Method: MethodLink},
+		}},
+		{"contacts", []Finding{
+			{Type: RecordContactForm, Value: "https://example.com/contact/send", Method: MethodForm},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			extractors, err := NewExtractors([]string{test.name}, &CrawlOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := extractors[0].Extract(testPage(t))
+			if len(got) != len(test.expected) {
+				t.Fatalf("Extract() = %v, want %v", got, test.expected)
+			}
+			for i := range got {
+				// the context is tested by TestExtractContext
+				got[i].Context = Context{}
+				if got[i] != test.expected[i] {
+					t.Errorf("Extract()[%d] = %v, want %v", i, got[i], test.expected[i])
+				}
+			}
+		})
+	}
+}
+
+type testExtractor struct{}
+
+func (testExtractor) Extract(page *Page) []Finding {
+	return []Finding{{Type: "title", Value: page.URL, Method: MethodText}}
+}
+
+func TestRegisterExtractor(t *testing.T) {
+	defer func(registered []registeredExtractor) { extractors = registered }(extractors)
+
+	RegisterExtractor("titles", func(opt *CrawlOptions) (Extractor, error) {
+		return testExtractor{}, nil
+	})
+	got, err := NewExtractors([]string{"titles", "emails"}, &CrawlOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("NewExtractors() = %v", got)
+	}
+	if _, ok := got[1].(testExtractor); !ok {
+		t.Errorf("NewExtractors()[1] = %T, want the registered extractor last", got[1])
+	}
+	if _, err := NewExtractors([]string{"faxes"}, &CrawlOptions{}); err == nil {
+		t.Error("NewExtractors() = nil error for faxes")
+	}
+}
@@ -0,0 +1,83 @@
+package pkg
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+)
+
+// sniffLen is how many of the first bytes of a body http.DetectContentType
+// looks at.
+const sniffLen = 512
+
+var errBodyTooLarge = errors.New("body too large")
+
+// response is the response of a GET of a page, with its content type, but
+// its body not read past the first bytes sniffed for it.
+type response struct {
+	*http.Response
+	// contentType is the served one, or the sniffed one when served without
+	// one or as anything
+	contentType string
+	body        *bufio.Reader
+	// data is the body as read by getWithRetries, nil when not read
+	data []byte
+}
+
+// get requests url with a single GET, and reads no more of its body than
+// needed to sniff its content type, so the body of unwanted content is
+// never downloaded. The request, body included, is cancelled once ctx is
+// done. The body of the response is to be closed.
+func (hc *HTTPChallenge) get(ctx context.Context, url string) (*response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	resp, err := hc.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	r := &response{Response: resp, body: bufio.NewReaderSize(resp.Body, sniffLen)}
+	// an error reading leaves less to sniff, and fails the read of the body
+	peek, _ := r.body.Peek(sniffLen)
+	r.contentType = sniffContentType(resp.Header.Get("Content-Type"), peek)
+	return r, nil
+}
+
+// read reads the body of r, failing with errBodyTooLarge, without
+// downloading more, past limit bytes.
+func (r *response) read(limit int64) ([]byte, error) {
+	if r.ContentLength > limit {
+		return nil, errBodyTooLarge
+	}
+	data, err := io.ReadAll(io.LimitReader(r.body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, errBodyTooLarge
+	}
+	return data, nil
+}
+
+// sniffContentType returns the content type served, or, when served without
+// one or as anything, the one of the first bytes of the body data, like
+// html or a pdf. Zip files and unknown binaries keep the served one, for
+// the extension of their url to tell what they are.
+func sniffContentType(served string, data []byte) string {
+	switch mediaType, _, _ := mime.ParseMediaType(served); mediaType {
+	case "", "application/octet-stream", "binary/octet-stream":
+	default:
+		return served
+	}
+	sniffed := http.DetectContentType(data)
+	switch mediaType, _, _ := mime.ParseMediaType(sniffed); mediaType {
+	case "application/octet-stream", "application/zip":
+		return served
+	}
+	return sniffed
+}
@@ -0,0 +1,113 @@
+package pkg
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSniffContentType(t *testing.T) {
+	tests := []struct {
+		served, body, want string
+	}{
+		{"text/html", "%PDF-1.4", "text/html"},
+		{"", "<!DOCTYPE html><html><body>hi</body></html>", "text/html; charset=utf-8"},
+		{"application/octet-stream", "%PDF-1.4\n", "application/pdf"},
+		{"", "Write to jane@acme.com", "text/plain; charset=utf-8"},
+		{"application/octet-stream", "PK\x03\x04 a docx", "application/octet-stream"},
+		{"", "\x00\x01\x02", ""},
+	}
+	for _, tt := range tests {
+		if got := sniffContentType(tt.served, []byte(tt.body)); got != tt.want {
+			t.Errorf("sniffContentType(%q, %q) = %q, want %q", tt.served, tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestCrawlSingleGET(t *testing.T) {
+	mu := sync.Mutex{}
+	requests := map[string][]string{}
+	mux := http.NewServeMux()
+	handle := func(path string, fn http.HandlerFunc) {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			requests[r.URL.Path] = append(requests[r.URL.Path], r.Method)
+			mu.Unlock()
+			if r.Method != http.MethodGet {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			fn(w, r)
+		})
+	}
+	handle("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><body>home@acme.com <a href="/untyped">untyped</a> <a href="/big">big</a> <a href="/video">video</a></body></html>`))
+	})
+	handle("/untyped", func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Content-Type"] = nil
+		_, _ = w.Write([]byte(`<!DOCTYPE html><html><head><title>Untyped</title></head><body>untyped@acme.com</body></html>`))
+	})
+	handle("/big", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("<html><body>" + strings.Repeat("big@acme.com ", 1000) + "</body></html>"))
+	})
+	handle("/video", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "video/mp4")
+		_, _ = w.Write([]byte(strings.Repeat("video@acme.com ", 1000)))
+	})
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+
+	hc := newTestHTTPChallenge(ts, "", false)
+	hc.options.URL = ts.URL
+	hc.options.MaxBodyBytes = 4096
+	records := &recordSink{}
+	hc.AddSink(records)
+	hc.CrawlRecursiveParallel(context.Background(), ts.URL)
+
+	got := map[string]Record{}
+	for _, r := range records.Records() {
+		got[r.Value] = r
+	}
+	if len(got) != 2 || got["untyped@acme.com"].PageTitle != "Untyped" || got["home@acme.com"].Value == "" {
+		t.Errorf("records = %v, want home@acme.com, and untyped@acme.com titled Untyped", got)
+	}
+	for path, methods := range requests {
+		if len(methods) != 1 || methods[0] != http.MethodGet {
+			t.Errorf("requests of %s = %v, want a single GET", path, methods)
+		}
+	}
+	if len(requests) != 5 {
+		t.Errorf("requested %v, want robots.txt and the 4 pages", requests)
+	}
+	if len(hc.SkippedURLs) != 1 || hc.SkippedURLs[0].URL != ts.URL+"/big" {
+		t.Errorf("SkippedURLs = %v, want %s", hc.SkippedURLs, ts.URL+"/big")
+	}
+}
+
+func TestCrawlCancelsRequests(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(ts.Close)
+
+	hc := newTestHTTPChallenge(ts, "", false)
+	hc.options.IgnoreRobots = true
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	hc.CrawlURLsWithWorkerPool(ctx, []string{ts.URL + "/slow"})
+
+	// well before the 5s timeout of the client
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("crawl took %v, want the request cancelled with ctx", elapsed)
+	}
+	if len(hc.FailedURLs) != 0 {
+		t.Errorf("FailedURLs = %v, want none for a cancelled request", hc.FailedURLs)
+	}
+}
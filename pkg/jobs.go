@@ -0,0 +1,276 @@
+package pkg
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Statuses of a Job
+const (
+	JobRunning   = "running"
+	JobDone      = "done"
+	JobCancelled = "cancelled"
+)
+
+var ErrJobNotFound = errors.New("job not found")
+
+// The largest options a job may be started with, so a request cannot have
+// the server start millions of goroutines or buffer gigabytes per url.
+const (
+	maxJobWorkers       = 500
+	maxJobVerifyWorkers = 100
+	maxJobTimeout       = 5 * time.Minute
+	maxJobBodyBytes     = 1 << 30
+)
+
+// Job is a crawl run by the server, with its own crawler so jobs do not
+// share urls, emails or host politeness.
+type Job struct {
+	ID      string
+	Options CrawlOptions
+
+	hc      *HTTPChallenge
+	records *recordSink
+	events  *EventLog
+	done    chan struct{}
+
+	mu         sync.Mutex
+	status     string
+	startedAt  time.Time
+	finishedAt time.Time
+}
+
+// JobInfo is the status and counters of a job.
+type JobInfo struct {
+	ID         string       `json:"id"`
+	Status     string       `json:"status"`
+	Options    CrawlOptions `json:"options"`
+	StartedAt  time.Time    `json:"started_at"`
+	FinishedAt *time.Time   `json:"finished_at,omitempty"`
+	Stats      CrawlStats   `json:"stats"`
+	Skipped    []SkippedURL `json:"skipped"`
+	Failed     []FailedURL  `json:"failed"`
+}
+
+func (j *Job) Info() JobInfo {
+	j.mu.Lock()
+	info := JobInfo{
+		ID:        j.ID,
+		Status:    j.status,
+		Options:   j.Options,
+		StartedAt: j.startedAt,
+	}
+	if !j.finishedAt.IsZero() {
+		finishedAt := j.finishedAt
+		info.FinishedAt = &finishedAt
+	}
+	j.mu.Unlock()
+
+	info.Stats = j.hc.Stats()
+	j.hc.mu.Lock()
+	info.Skipped = append([]SkippedURL{}, j.hc.SkippedURLs...)
+	info.Failed = append([]FailedURL{}, j.hc.FailedURLs...)
+	j.hc.mu.Unlock()
+	return info
+}
+
+// Emails returns the email records found by the job so far.
+func (j *Job) Emails() []Record {
+	emails := []Record{}
+	for _, r := range j.records.Records() {
+		if r.Type == RecordEmail {
+			emails = append(emails, r)
+		}
+	}
+	return emails
+}
+
+// Records returns the records of all types found by the job so far.
+func (j *Job) Records() []Record {
+	return j.records.Records()
+}
+
+// Events returns the log of the events of the job, complete once the job
+// is finished.
+func (j *Job) Events() *EventLog {
+	return j.events
+}
+
+// Cancel stops the job from crawling more urls, the urls being crawled are
+// finished or time out.
+func (j *Job) Cancel() {
+	j.hc.Stop()
+}
+
+// Wait blocks until the job is finished.
+func (j *Job) Wait() {
+	<-j.done
+}
+
+// recordSink keeps the email records of a job in memory.
+type recordSink struct {
+	mu      sync.Mutex
+	records []Record
+}
+
+func (s *recordSink) Write(records []Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, records...)
+	return nil
+}
+
+func (s *recordSink) Close() error {
+	return nil
+}
+
+func (s *recordSink) Records() []Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Record{}, s.records...)
+}
+
+// Jobs runs crawl jobs concurrently and keeps them, finished ones included,
+// until the server stops.
+type Jobs struct {
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+func NewJobs() *Jobs {
+	return &Jobs{jobs: make(map[string]*Job)}
+}
+
+// Start starts a job crawling opt.URL recursively, as the command line does
+// with -url.
+func (js *Jobs) Start(opt CrawlOptions) (*Job, error) {
+	if opt.URL == "" {
+		return nil, errors.New("url is required")
+	}
+	if err := checkJobOptions(opt); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(opt.URL, "http") {
+		opt.URL = "https://" + opt.URL
+	}
+	// results are kept by the job, not written to files of the server
+	opt.WriteToFile = ""
+	opt.CrawlFromFile = false
+
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+	job := &Job{
+		ID:        id,
+		Options:   opt,
+		records:   &recordSink{},
+		events:    NewEventLog(),
+		done:      make(chan struct{}),
+		status:    JobRunning,
+		startedAt: time.Now().UTC(),
+	}
+	hc, err := NewHTTPChallenge(func(o *CrawlOptions) error {
+		*o = opt
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	job.hc = hc
+	job.hc.AddSink(job.records)
+	job.hc.Observe(job.events.Append)
+
+	js.mu.Lock()
+	js.jobs[id] = job
+	js.mu.Unlock()
+
+	go func() {
+		defer close(job.done)
+		job.hc.CrawlRecursiveParallel(context.Background(), opt.URL)
+		// the record sink does not fail
+		_ = job.hc.Close()
+
+		job.mu.Lock()
+		job.finishedAt = time.Now().UTC()
+		job.status = JobDone
+		if job.hc.Stopped() {
+			job.status = JobCancelled
+		}
+		status := job.status
+		job.mu.Unlock()
+
+		stats := job.hc.Stats()
+		job.events.Append(Event{Type: EventCompleted, Stats: &stats, JobStatus: status})
+		job.events.Close()
+	}()
+	return job, nil
+}
+
+// checkJobOptions fails on options out of the bounds of a job. Unlike the
+// command line, zero values are not taken for the defaults.
+func checkJobOptions(opt CrawlOptions) error {
+	switch {
+	case opt.MaxWorkers <= 0 || opt.MaxWorkers > maxJobWorkers:
+		return fmt.Errorf("max_workers must be between 1 and %d", maxJobWorkers)
+	case opt.VerifyWorkers <= 0 || opt.VerifyWorkers > maxJobVerifyWorkers:
+		return fmt.Errorf("verify_workers must be between 1 and %d", maxJobVerifyWorkers)
+	case opt.TimeoutMillisecond <= 0 || opt.TimeoutMillisecond > maxJobTimeout.Milliseconds():
+		return fmt.Errorf("timeout must be between 1 and %d milliseconds", maxJobTimeout.Milliseconds())
+	case opt.MaxBodyBytes <= 0 || opt.MaxBodyBytes > maxJobBodyBytes:
+		return fmt.Errorf("max_body_bytes must be between 1 and %d", maxJobBodyBytes)
+	case opt.MaxDocumentBytes <= 0 || opt.MaxDocumentBytes > maxJobBodyBytes:
+		return fmt.Errorf("max_document_bytes must be between 1 and %d", maxJobBodyBytes)
+	}
+	return nil
+}
+
+func (js *Jobs) Get(id string) (*Job, error) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	job, ok := js.jobs[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	return job, nil
+}
+
+// List returns all jobs, latest first.
+func (js *Jobs) List() []*Job {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	jobs := make([]*Job, 0, len(js.jobs))
+	for _, job := range js.jobs {
+		jobs = append(jobs, job)
+	}
+	slices.SortFunc(jobs, func(a, b *Job) int {
+		return b.startedAt.Compare(a.startedAt)
+	})
+	return jobs
+}
+
+// CancelAll cancels all jobs and waits for them to finish.
+func (js *Jobs) CancelAll() {
+	jobs := js.List()
+	for _, job := range jobs {
+		job.Cancel()
+	}
+	for _, job := range jobs {
+		job.Wait()
+	}
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating job id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -1,7 +1,6 @@
 package pkg
 
 import (
-	"fmt"
 	"sync"
 
 	"github.com/sirupsen/logrus"
@@ -14,7 +13,6 @@ var (
 
 func Logger() *logrus.Logger {
 	loggerOnce.Do(func() {
-		fmt.Println("Logger initialized")
 		log = logrus.New()
 		log.SetFormatter(&logrus.TextFormatter{
 			DisableColors:   false,
@@ -0,0 +1,69 @@
+package pkg
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var (
+	// emailRegexp matches candidates, validated by NormalizeEmail
+	emailRegexp      = regexp.MustCompile(`^[^\s@]+@[^\s@]+\.[^\s@]+$`)
+	mailtoHrefRegexp = regexp.MustCompile(`(?i)href\s*=\s*("mailto:[^"]*"|'mailto:[^']*')`)
+)
+
+// ParseMailto returns the recipients of a mailto href: the addresses before
+// the query, comma separated and url encoded, and those of its to, cc and
+// bcc params.
+func ParseMailto(href string) []string {
+	href = strings.TrimSpace(href)
+	if len(href) < len("mailto:") || !strings.EqualFold(href[:len("mailto:")], "mailto:") {
+		return []string{}
+	}
+	href = href[len("mailto:"):]
+
+	to, query, _ := strings.Cut(href, "?")
+	recipients := []string{to}
+	// not url.ParseQuery, which takes + for a space
+	for _, param := range strings.Split(query, "&") {
+		key, value, _ := strings.Cut(param, "=")
+		if StringInSlice(strings.ToLower(key), []string{"to", "cc", "bcc"}) {
+			recipients = append(recipients, value)
+		}
+	}
+
+	emails := []string{}
+	for _, r := range recipients {
+		if decoded, err := url.PathUnescape(r); err == nil {
+			r = decoded
+		}
+		for _, email := range strings.FieldsFunc(r, func(c rune) bool { return c == ',' || c == ';' }) {
+			email = strings.TrimSpace(email)
+			if emailRegexp.MatchString(email) {
+				emails = append(emails, email)
+			}
+		}
+	}
+	return UniqueStrings(emails)
+}
+
+// ExtractEmailMatchesFromMailtos returns the recipients of the mailto links
+// in dom.
+func ExtractEmailMatchesFromMailtos(dom *goquery.Selection) []EmailMatch {
+	matches := []EmailMatch{}
+	dom.Find("a[href], area[href]").Each(func(_ int, s *goquery.Selection) {
+		href, _ := s.Attr("href")
+		for _, email := range ParseMailto(href) {
+			matches = append(matches, EmailMatch{Email: email, Method: MethodMailto})
+		}
+	})
+	return matches
+}
+
+// StripMailtoHrefs removes the mailto hrefs from html, so the text pass does
+// not pick up their recipients mangled by url encoding.
+func StripMailtoHrefs(html string) string {
+	return mailtoHrefRegexp.ReplaceAllString(html, `href=""`)
+}
@@ -0,0 +1,59 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestParseMailto(t *testing.T) {
+	tests := []struct {
+		href     string
+		expected []string
+	}{
+		{"mailto:john@example.com", []string{"john@example.com"}},
+		{"MAILTO:john@example.com", []string{"john@example.com"}},
+		{"mailto:john%40example.com", []string{"john@example.com"}},
+		{"mailto:%20john@example.com?subject=Hello%20there", []string{"john@example.com"}},
+		{"mailto:a@example.com,b@example.com", []string{"a@example.com", "b@example.com"}},
+		{"mailto:a@example.com;%20b@example.com", []string{"a@example.com", "b@example.com"}},
+		{"mailto:a+news@example.com?cc=b@example.com&bcc=c%40example.com&body=d@example.com", []string{"a+news@example.com", "b@example.com", "c@example.com"}},
+		{"mailto:?to=a@example.com", []string{"a@example.com"}},
+		{"mailto:not-an-email", []string{}},
+		{"https://example.com/mailto:john@example.com", []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.href, func(t *testing.T) {
+			got := ParseMailto(test.href)
+			if len(got) == 0 && len(test.expected) == 0 {
+				return
+			}
+			if !IsEqualSlice(got, test.expected) {
+				t.Errorf("ParseMailto(%q) = %v, want %v", test.href, got, test.expected)
+			}
+		})
+	}
+}
+
+func TestExtractEmailMatchesFromMailtos(t *testing.T) {
+	html := `<html><body>
+<a href="mailto:sales%40example.com?subject=Quote">Sales</a>
+<a href="/contact">Contact</a>
+<map><area href="mailto:support@example.com"></map>
+</body></html>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	matches := ExtractEmailMatchesFromMailtos(doc.Selection)
+	want := []EmailMatch{{"sales@example.com", MethodMailto}, {"support@example.com", MethodMailto}}
+	if len(matches) != len(want) || matches[0] != want[0] || matches[1] != want[1] {
+		t.Errorf("ExtractEmailMatchesFromMailtos() = %v, want %v", matches, want)
+	}
+
+	stripped := StripMailtoHrefs(html)
+	if got := ExtractEmailsFromText(stripped); len(got) != 0 {
+		t.Errorf("ExtractEmailsFromText(StripMailtoHrefs()) = %v, want none", got)
+	}
+}
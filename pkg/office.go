@@ -0,0 +1,154 @@
+package pkg
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+var (
+	// officeParts are the parts of docx, xlsx and pptx files with text: the
+	// bodies, headers, footers, notes and comments of documents, the shared
+	// strings and cells of spreadsheets, the slides and their notes, and the
+	// core and app properties, like the author and company
+	officeParts = regexp.MustCompile(`^(word/(document|header\d*|footer\d*|footnotes|endnotes|comments)|xl/(sharedStrings|worksheets/sheet\d+|comments\d+)|ppt/(slides/slide\d+|notesSlides/notesSlide\d+|comments/comment\d+)|docProps/(core|app))\.xml$`)
+	// odfParts are the parts of odt, ods and odp files with text: the
+	// content, the headers and footers of the styles, and the metadata
+	odfParts = regexp.MustCompile(`^(content|styles|meta)\.xml$`)
+)
+
+const dublinCoreNamespace = "http://purl.org/dc/elements/1.1/"
+
+// zipDocumentMaxBytes is how much of the parts of a zipped document is
+// uncompressed at most, against zip bombs.
+var zipDocumentMaxBytes int64 = 64 << 20
+
+var errZipDocumentTooLarge = errors.New("uncompressed document too large")
+
+// xmlLineElements are the elements ending a line of text, like paragraphs
+// and rows, and xmlSpaceElements the ones ending a word, like cells and
+// tabs, by their local name in OOXML and ODF. The runs of a paragraph are
+// joined as they are, as they split words, even addresses, anywhere.
+var (
+	xmlLineElements  = []string{"p", "h", "br", "cr", "tr", "row", "si", "table-row", "line-break", "list-item", "creator", "lastModifiedBy", "title", "subject", "description", "keywords", "initial-creator", "Company", "Manager"}
+	xmlSpaceElements = []string{"tab", "tc", "c", "table-cell", "s", "instrText"}
+)
+
+// ReadOffice returns the text of an OOXML document, spreadsheet or
+// presentation, with the urls of its hyperlinks.
+func ReadOffice(data []byte) (*Document, error) {
+	return readZipDocument(data, officeParts)
+}
+
+// ReadODF returns the text of an OpenDocument text, spreadsheet or
+// presentation, with the urls of its links.
+func ReadODF(data []byte) (*Document, error) {
+	return readZipDocument(data, odfParts)
+}
+
+// readZipDocument returns the text of the xml parts of the zipped document
+// data, the urls of their links and of the external targets of its
+// relationships, and the dublin core title.
+func readZipDocument(data []byte, parts *regexp.Regexp) (*Document, error) {
+	z, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("error reading document: %w", err)
+	}
+	doc := &Document{}
+	text := strings.Builder{}
+	remaining := zipDocumentMaxBytes
+	for _, f := range z.File {
+		isRels := strings.HasSuffix(f.Name, ".rels")
+		if !isRels && !parts.MatchString(f.Name) {
+			continue
+		}
+		if remaining <= 0 {
+			return nil, errZipDocumentTooLarge
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", f.Name, err)
+		}
+		r := &io.LimitedReader{R: rc, N: remaining}
+		if isRels {
+			err = readRelationshipLinks(r, doc)
+		} else {
+			err = readXMLText(r, doc, &text)
+		}
+		rc.Close()
+		if remaining = r.N; remaining <= 0 {
+			return nil, errZipDocumentTooLarge
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", f.Name, err)
+		}
+	}
+	doc.Text = text.String()
+	return doc, nil
+}
+
+// readXMLText writes the text of the xml of r to text, with line breaks and
+// spaces between its paragraphs and cells, and adds its links to doc.
+func readXMLText(r io.Reader, doc *Document, text *strings.Builder) error {
+	d := xml.NewDecoder(r)
+	// like &nbsp; in hand written files
+	d.Strict = false
+	title := false
+	for {
+		token, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			for _, attr := range t.Attr {
+				if attr.Name.Local == "href" && attr.Value != "" {
+					doc.Links = append(doc.Links, attr.Value)
+				}
+			}
+			title = t.Name.Local == "title" && t.Name.Space == dublinCoreNamespace && doc.Title == ""
+		case xml.CharData:
+			text.Write(t)
+			if title {
+				doc.Title = strings.TrimSpace(string(t))
+			}
+		case xml.EndElement:
+			title = false
+			switch {
+			case StringInSlice(t.Name.Local, xmlLineElements):
+				text.WriteString("\n")
+			case StringInSlice(t.Name.Local, xmlSpaceElements):
+				text.WriteString(" ")
+			}
+		}
+	}
+}
+
+// readRelationshipLinks adds the external hyperlink targets of the OOXML
+// relationships of r to doc, like mailto links.
+func readRelationshipLinks(r io.Reader, doc *Document) error {
+	var rels struct {
+		Relationships []struct {
+			Type       string `xml:"Type,attr"`
+			Target     string `xml:"Target,attr"`
+			TargetMode string `xml:"TargetMode,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := xml.NewDecoder(r).Decode(&rels); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	for _, rel := range rels.Relationships {
+		if rel.TargetMode == "External" && strings.HasSuffix(rel.Type, "/hyperlink") {
+			doc.Links = append(doc.Links, rel.Target)
+		}
+	}
+	return nil
+}
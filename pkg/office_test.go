@@ -0,0 +1,149 @@
+package pkg
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// testZip returns a zip of files, by name.
+func testZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	b := bytes.Buffer{}
+	z := zip.NewWriter(&b)
+	for name, content := range files {
+		w, err := z.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestReadOffice(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		title string
+		text  []string
+		links []string
+	}{
+		{
+			name: "docx",
+			files: map[string]string{
+				"word/document.xml": `<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>
+<w:p><w:r><w:t>Chair: </w:t></w:r><w:r><w:t>jane.roe</w:t></w:r><w:r><w:t>@acme.com</w:t></w:r></w:p>
+<w:tbl><w:tr><w:tc><w:p><w:r><w:t>Speaker</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>alan@acme.com</w:t></w:r></w:p></w:tc></w:tr></w:tbl>
+<w:p><w:hyperlink r:id="rId5"><w:r><w:t>Write to the committee</w:t></w:r></w:hyperlink></w:p></w:body></w:document>`,
+				"word/_rels/document.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId5" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink" Target="mailto:committee@acme.com" TargetMode="External"/>
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/></Relationships>`,
+				"docProps/core.xml": `<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<dc:title>Committee roster</dc:title><dc:creator>ada@acme.com</dc:creator></cp:coreProperties>`,
+				"word/styles.xml": `<w:styles><w:t>styles@acme.com</w:t></w:styles>`,
+			},
+			title: "Committee roster",
+			text:  []string{"Chair: jane.roe@acme.com\n", "Speaker\n alan@acme.com", "ada@acme.com\n"},
+			links: []string{"mailto:committee@acme.com"},
+		},
+		{
+			name: "xlsx",
+			files: map[string]string{
+				"xl/sharedStrings.xml": `<sst><si><t>Name</t></si><si><t>Email</t></si><si><r><t>grace</t></r><r><t>@acme.com</t></r></si></sst>`,
+				"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row><c t="s"><v>0</v></c><c t="s"><v>1</v></c></row>
+<row><c t="inlineStr"><is><t>Linus Pauling</t></is></c><c t="inlineStr"><is><t>linus@acme.com</t></is></c></row></sheetData></worksheet>`,
+			},
+			text: []string{"grace@acme.com\n", "Linus Pauling linus@acme.com"},
+		},
+		{
+			name: "odt",
+			files: map[string]string{
+				"mimetype": "application/vnd.oasis.opendocument.text",
+				"content.xml": `<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0" xmlns:xlink="http://www.w3.org/1999/xlink">
+<office:body><office:text><text:h>Speakers</text:h><text:p>Ada Lovelace<text:tab/>ada@acme.com</text:p>
+<text:p>Press:<text:s/><text:a xlink:href="mailto:press@acme.com">write</text:a></text:p></office:text></office:body></office:document-content>`,
+				"meta.xml": `<office:document-meta xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:meta="urn:oasis:names:tc:opendocument:xmlns:meta:1.0">
+<office:meta><dc:title>Speakers</dc:title><meta:initial-creator>Alan Turing</meta:initial-creator></office:meta></office:document-meta>`,
+			},
+			title: "Speakers",
+			text:  []string{"Speakers\n", "Ada Lovelace ada@acme.com\n", "Press: write", "Alan Turing"},
+			links: []string{"mailto:press@acme.com"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			read := ReadOffice
+			if test.name == "odt" {
+				read = ReadODF
+			}
+			doc, err := read(testZip(t, test.files))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if doc.Title != test.title {
+				t.Errorf("Title = %q, want %q", doc.Title, test.title)
+			}
+			for _, text := range test.text {
+				if !strings.Contains(doc.Text, text) {
+					t.Errorf("Text = %q, want it to have %q", doc.Text, text)
+				}
+			}
+			if strings.Contains(doc.Text, "styles@acme.com") {
+				t.Errorf("Text = %q, want no text of the styles", doc.Text)
+			}
+			if strings.Join(doc.Links, " ") != strings.Join(test.links, " ") {
+				t.Errorf("Links = %v, want %v", doc.Links, test.links)
+			}
+			emails := ExtractEmailsFromText(doc.Text)
+			if len(emails) == 0 {
+				t.Errorf("no emails in %q", doc.Text)
+			}
+		})
+	}
+
+	if _, err := ReadOffice([]byte("PK not really")); err == nil {
+		t.Error("ReadOffice() = nil error for a malformed file")
+	}
+}
+
+func TestReadOfficeTooLarge(t *testing.T) {
+	defer func(max int64) { zipDocumentMaxBytes = max }(zipDocumentMaxBytes)
+	zipDocumentMaxBytes = 1 << 10
+	// a part of spaces compresses to next to nothing
+	data := testZip(t, map[string]string{"word/document.xml": "<w:t>" + strings.Repeat(" ", 1<<20) + "</w:t>"})
+	if _, err := ReadOffice(data); err != errZipDocumentTooLarge {
+		t.Errorf("ReadOffice() error = %v, want %v", err, errZipDocumentTooLarge)
+	}
+}
+
+func TestDocumentFormatOf(t *testing.T) {
+	formats, err := ParseDocumentFormats(DocumentFormatNames())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		contentType string
+		url         string
+		expected    string
+	}{
+		{"application/pdf", "https://acme.com/download?id=1", "pdf"},
+		{"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "https://acme.com/list", "office"},
+		{"application/octet-stream", "https://acme.com/roster.DOCX", "office"},
+		{"", "https://acme.com/speakers.odt?v=2", "odf"},
+		{"text/html; charset=utf-8", "https://acme.com/report.pdf", ""},
+		{"application/zip", "https://acme.com/archive.zip", ""},
+	}
+	for _, test := range tests {
+		format, _ := documentFormatOf(formats, test.contentType, test.url)
+		if format.Name != test.expected {
+			t.Errorf("documentFormatOf(%q, %q) = %q, want %q", test.contentType, test.url, format.Name, test.expected)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package pkg
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Output formats of -format
+const (
+	FormatTXT   = "txt"
+	FormatJSONL = "jsonl"
+	FormatCSV   = "csv"
+	FormatJSON  = "json"
+)
+
+// Types of a Record
+const (
+	RecordEmail       = "email"
+	RecordPhone       = "phone"
+	RecordSocial      = "social"
+	RecordContactForm = "contact_form"
+)
+
+// Extraction methods of a Record
+const (
+	MethodText         = "text"
+	MethodMailto       = "mailto"
+	MethodDeobfuscated = "deobfuscated"
+	MethodTel          = "tel"
+	MethodLink         = "link"
+	MethodForm         = "form"
+	MethodJSONLD       = "jsonld"
+	MethodMicrodata    = "microdata"
+	MethodHCard        = "hcard"
+	MethodVCard        = "vcard"
+	MethodICal         = "ical"
+	MethodFeed         = "feed"
+)
+
+// methodConfidence is how likely a finding of a method is what it seems,
+// structured data being published for machines to read.
+var methodConfidence = map[string]float64{
+	MethodJSONLD:       0.95,
+	MethodMicrodata:    0.9,
+	MethodHCard:        0.9,
+	MethodVCard:        0.9,
+	MethodICal:         0.85,
+	MethodFeed:         0.85,
+	MethodMailto:       0.8,
+	MethodTel:          0.8,
+	MethodLink:         0.8,
+	MethodForm:         0.8,
+	MethodDeobfuscated: 0.6,
+	MethodText:         0.5,
+}
+
+// Confidence returns the confidence, from 0 to 1, of the findings of method.
+func Confidence(method string) float64 {
+	return methodConfidence[method]
+}
+
+var Formats = []string{FormatTXT, FormatJSONL, FormatCSV, FormatJSON}
+
+var recordCSVHeader = []string{"type", "value", "source_url", "page_title", "status", "discovered_at", "depth", "method", "class", "mx", "name", "heading", "snippet", "job_title", "organization", "confidence"}
+
+// RecordSink stores the records of a crawl as they are found.
+type RecordSink interface {
+	Write(records []Record) error
+	Close() error
+}
+
+// PageRecordSink is a RecordSink of all the records of each page, also the
+// ones found before on other pages, like a database linking an email to
+// every page it is on. WritePage is called instead of Write.
+type PageRecordSink interface {
+	RecordSink
+	WritePage(records []Record) error
+}
+
+// Record is something found on a page, an email or another type of the
+// extractors, with where, when and how it was found, how confident that
+// method is, and the name, heading and snippet of its context, and job title
+// and organization of structured data. Class is the class of emails, and MX
+// the MX status of their domain when verified.
+type Record struct {
+	Type         string    `json:"type"`
+	Value        string    `json:"value"`
+	SourceURL    string    `json:"source_url"`
+	PageTitle    string    `json:"page_title"`
+	Status       int       `json:"status"`
+	DiscoveredAt time.Time `json:"discovered_at"`
+	Depth        int       `json:"depth"`
+	Method       string    `json:"method"`
+	Class        string    `json:"class,omitempty"`
+	MX           string    `json:"mx,omitempty"`
+	Name         string    `json:"name,omitempty"`
+	Heading      string    `json:"heading,omitempty"`
+	Snippet      string    `json:"snippet,omitempty"`
+	JobTitle     string    `json:"job_title,omitempty"`
+	Organization string    `json:"organization,omitempty"`
+	Confidence   float64   `json:"confidence"`
+}
+
+func (r Record) csvRow() []string {
+	return []string{
+		r.Type,
+		r.Value,
+		r.SourceURL,
+		r.PageTitle,
+		strconv.Itoa(r.Status),
+		r.DiscoveredAt.Format(time.RFC3339),
+		strconv.Itoa(r.Depth),
+		r.Method,
+		r.Class,
+		r.MX,
+		r.Name,
+		r.Heading,
+		r.Snippet,
+		r.JobTitle,
+		r.Organization,
+		strconv.FormatFloat(r.Confidence, 'f', 2, 64),
+	}
+}
+
+// RecordWriter writes records to a file in one of Formats. The txt,
+// jsonl and csv formats are appended as records are written, json holds
+// them until Close as it is a single array. The txt format is a list of
+// emails, the records of other types are left to the other formats.
+type RecordWriter struct {
+	path   string
+	format string
+
+	mu      sync.Mutex
+	records []Record
+}
+
+func NewRecordWriter(path, format string) (*RecordWriter, error) {
+	if !StringInSlice(format, Formats) {
+		return nil, fmt.Errorf("unknown format %q, use one of %v", format, Formats)
+	}
+	return &RecordWriter{path: path, format: format}, nil
+}
+
+func (w *RecordWriter) Write(records []Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.format == FormatJSON {
+		w.records = append(w.records, records...)
+		return nil
+	}
+
+	file, err := os.OpenFile(w.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	switch w.format {
+	case FormatJSONL:
+		enc := json.NewEncoder(file)
+		for _, r := range records {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+		}
+	case FormatCSV:
+		cw := csv.NewWriter(file)
+		info, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		if info.Size() == 0 {
+			if err := cw.Write(recordCSVHeader); err != nil {
+				return err
+			}
+		}
+		for _, r := range records {
+			if err := cw.Write(r.csvRow()); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		for _, r := range records {
+			if r.Type != RecordEmail {
+				continue
+			}
+			if _, err := file.WriteString(r.Value + "\n"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Close writes the records held for the json format, after the records of
+// a previous crawl already in the file.
+func (w *RecordWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.format != FormatJSON {
+		return nil
+	}
+
+	records := []Record{}
+	if b, err := os.ReadFile(w.path); err == nil && len(b) > 0 {
+		if err := json.Unmarshal(b, &records); err != nil {
+			return fmt.Errorf("error reading %s: %w", w.path, err)
+		}
+	}
+	records = append(records, w.records...)
+	b, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// write a whole array or nothing
+	tmp := w.path + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, w.path); err != nil {
+		return err
+	}
+	w.records = nil
+	return nil
+}
@@ -0,0 +1,129 @@
+package pkg
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testRecords() []Record {
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	return []Record{
+		{Type: RecordEmail, Value: "a@example.com", SourceURL: "https://example.com/", PageTitle: "Home, sweet home", Status: 200, DiscoveredAt: at, Depth: 0, Method: MethodText},
+		{Type: RecordEmail, Value: "b@example.com", SourceURL: "https://example.com/team", PageTitle: "Team", Status: 200, DiscoveredAt: at, Depth: 1, Method: MethodDeobfuscated},
+	}
+}
+
+// writeTwice writes the test records in two crawls, as a resumed crawl
+// appends to the output file.
+func writeTwice(t *testing.T, format string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "emails."+format)
+	for _, r := range testRecords() {
+		w, err := NewRecordWriter(path, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Write([]Record{r}); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func TestRecordWriter(t *testing.T) {
+	want := testRecords()
+
+	t.Run(FormatTXT, func(t *testing.T) {
+		b, err := os.ReadFile(writeTwice(t, FormatTXT))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != "a@example.com\nb@example.com\n" {
+			t.Errorf("txt = %q", b)
+		}
+
+		path := filepath.Join(t.TempDir(), "emails.txt")
+		w, err := NewRecordWriter(path, FormatTXT)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Write([]Record{
+			{Type: RecordPhone, Value: "+14155550100"},
+			{Type: RecordEmail, Value: "c@example.com"},
+			{Type: RecordSocial, Value: "https://github.com/acme"},
+			{Type: RecordContactForm, Value: "https://example.com/contact"},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if b, _ := os.ReadFile(path); string(b) != "c@example.com\n" {
+			t.Errorf("txt = %q, want only the email", b)
+		}
+	})
+
+	t.Run(FormatJSONL, func(t *testing.T) {
+		b, err := os.ReadFile(writeTwice(t, FormatJSONL))
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+		if len(lines) != len(want) {
+			t.Fatalf("jsonl has %d lines, want %d", len(lines), len(want))
+		}
+		for i, line := range lines {
+			var got Record
+			if err := json.Unmarshal([]byte(line), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got != want[i] {
+				t.Errorf("line %d = %+v, want %+v", i, got, want[i])
+			}
+		}
+	})
+
+	t.Run(FormatCSV, func(t *testing.T) {
+		file, err := os.Open(writeTwice(t, FormatCSV))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		rows, err := csv.NewReader(file).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// a single header
+		if len(rows) != 3 || !IsEqualSlice(rows[0], recordCSVHeader) {
+			t.Fatalf("csv = %v", rows)
+		}
+		if !IsEqualSlice(rows[1], want[0].csvRow()) {
+			t.Errorf("row = %v, want %v", rows[1], want[0].csvRow())
+		}
+	})
+
+	t.Run(FormatJSON, func(t *testing.T) {
+		b, err := os.ReadFile(writeTwice(t, FormatJSON))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []Record
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+			t.Errorf("json = %+v, want %+v", got, want)
+		}
+	})
+}
+
+func TestNewRecordWriterUnknownFormat(t *testing.T) {
+	if _, err := NewRecordWriter("emails.xml", "xml"); err == nil {
+		t.Error("NewRecordWriter() = nil error for xml")
+	}
+}
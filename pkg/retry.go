@@ -0,0 +1,156 @@
+package pkg
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// The categories of the errors of a request, of the urls that failed.
+const (
+	FailureDNS               = "dns"
+	FailureConnectionRefused = "connection_refused"
+	FailureConnection        = "connection"
+	FailureTLS               = "tls"
+	FailureTimeout           = "timeout"
+	FailureServerError       = "http_5xx"
+	FailureTooManyRequests   = "http_429"
+	FailureOther             = "other"
+)
+
+// retryableFailures are the categories of errors that may pass on a retry:
+// refused and dropped connections, timeouts, server errors and rate limits.
+// Unknown hosts and invalid certificates stay so.
+var retryableFailures = []string{FailureConnectionRefused, FailureConnection, FailureTimeout, FailureServerError, FailureTooManyRequests}
+
+// defaultRetryBackoff is the delay before the first retry unless given
+// otherwise, doubled for each next one up to maxRetryBackoff.
+const (
+	defaultRetryBackoff = 500 * time.Millisecond
+	maxRetryBackoff     = 30 * time.Second
+)
+
+// FailedURL is a url that could not be crawled, after all its retries.
+type FailedURL struct {
+	URL      string `json:"url"`
+	Category string `json:"category"`
+	Error    string `json:"error"`
+	Attempts int    `json:"attempts"`
+}
+
+// classifyError returns the category of the error of a request.
+func classifyError(err error) string {
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	var tlsRecordErr tls.RecordHeaderError
+	var tlsAlert tls.AlertError
+	var certErr *tls.CertificateVerificationError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	var opErr *net.OpError
+	switch {
+	case errors.As(err, &dnsErr):
+		return FailureDNS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return FailureConnectionRefused
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return FailureTimeout
+	case errors.As(err, &tlsRecordErr), errors.As(err, &tlsAlert), errors.As(err, &certErr),
+		errors.As(err, &authorityErr), errors.As(err, &hostnameErr), errors.As(err, &invalidErr):
+		return FailureTLS
+	case errors.As(err, &opErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, syscall.ECONNRESET):
+		return FailureConnection
+	}
+	return FailureOther
+}
+
+// classifyStatus returns the category of a failed response status, empty
+// for the others, 404 pages included.
+func classifyStatus(status int) string {
+	switch {
+	case status == http.StatusTooManyRequests:
+		return FailureTooManyRequests
+	case status >= 500:
+		return FailureServerError
+	}
+	return ""
+}
+
+// retryable reports whether an error of category, err of a request when
+// not a failed response, may pass on a retry. DNS failures are retried
+// when temporary, like timeouts, not for unknown hosts.
+func retryable(category string, err error) bool {
+	var dnsErr *net.DNSError
+	if category == FailureDNS {
+		return errors.As(err, &dnsErr) && (dnsErr.IsTimeout || dnsErr.IsTemporary)
+	}
+	return StringInSlice(category, retryableFailures)
+}
+
+// retryDelay returns the delay before retry attempt, from 0, doubling
+// backoff for each attempt up to maxRetryBackoff, with a jitter of up to
+// half of it so workers failing together do not retry together.
+func retryDelay(attempt int, backoff time.Duration) time.Duration {
+	d := backoff
+	for i := 0; i < attempt && d < maxRetryBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, maxRetryBackoff)
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// getWithRetries gets url and reads up to limit(resp) bytes of its body,
+// none for 0, as reading the body may fail like the request. It retries up
+// to -retries times the errors and failed responses that may pass, after a
+// backoff, or as long as asked by the Retry-After of a rate limit, and once
+// the host is ready again. A body past the limit fails with errBodyTooLarge,
+// not retried. The caller holds a slot of the host of url. It returns the
+// category of the last error, empty on success, and the number of attempts.
+func (hc *HTTPChallenge) getWithRetries(ctx context.Context, url string, limit func(resp *response) int64) (*response, string, int, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := hc.get(ctx, url)
+		category := ""
+		var retryAfter time.Duration
+		if err != nil {
+			category = classifyError(err)
+		} else {
+			hc.scheduler.Report(url, resp.StatusCode, resp.Header)
+			if category = classifyStatus(resp.StatusCode); category != "" {
+				err = errors.New(resp.Status)
+				retryAfter, _ = ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+			} else if n := limit(resp); n > 0 {
+				if resp.data, err = resp.read(n); err != nil {
+					category = classifyError(err)
+				}
+			}
+			if err == nil {
+				return resp, "", attempt + 1, nil
+			}
+			if errors.Is(err, errBodyTooLarge) {
+				resp.Body.Close()
+				return nil, "", attempt + 1, err
+			}
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		if attempt >= hc.options.Retries || !retryable(category, err) {
+			return nil, category, attempt + 1, err
+		}
+
+		delay := max(retryDelay(attempt, time.Duration(hc.options.BackoffMillisecond)*time.Millisecond), min(retryAfter, maxRetryBackoff))
+		Logger().WithField("url", url).Debugf("retrying in %s after %s: %s", delay, category, err)
+		// the backoff of the host, set by Report, holds the retry back too
+		if hc.scheduler.Wait(ctx, url, delay) != nil {
+			return nil, category, attempt + 1, err
+		}
+	}
+}
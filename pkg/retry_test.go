@@ -0,0 +1,200 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"io"
+	stdlog "log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClassifyError(t *testing.T) {
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	tlsServer := httptest.NewUnstartedServer(http.NotFoundHandler())
+	// the handshake rejected by the client is no error of the test
+	tlsServer.Config.ErrorLog = stdlog.New(io.Discard, "", 0)
+	tlsServer.StartTLS()
+	t.Cleanup(tlsServer.Close)
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	t.Cleanup(slow.Close)
+	hangUp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	}))
+	t.Cleanup(hangUp.Close)
+
+	get := func(u string, timeout time.Duration) error {
+		resp, err := (&http.Client{Timeout: timeout}).Get(u)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"unknown host", &url.Error{Op: "Get", URL: "http://acme.invalid", Err: &net.DNSError{Err: "no such host", Name: "acme.invalid", IsNotFound: true}}, FailureDNS},
+		{"refused", get(closed.URL, time.Second), FailureConnectionRefused},
+		{"untrusted certificate", get(tlsServer.URL, 5*time.Second), FailureTLS},
+		{"timeout", get(slow.URL, 50*time.Millisecond), FailureTimeout},
+		{"hung up", get(hangUp.URL, time.Second), FailureConnection},
+		{"other", errors.New("unsupported protocol scheme"), FailureOther},
+	}
+	for _, tt := range tests {
+		if got := classifyError(tt.err); got != tt.want {
+			t.Errorf("classifyError(%s: %v) = %q, want %q", tt.name, tt.err, got, tt.want)
+		}
+	}
+
+	if retryable(FailureDNS, tests[0].err) || !retryable(FailureDNS, &net.DNSError{IsTimeout: true}) {
+		t.Error("retryable(dns) want unknown hosts not retried, timeouts retried")
+	}
+	if retryable(FailureTLS, tests[2].err) || !retryable(FailureTimeout, tests[3].err) {
+		t.Error("retryable() want tls errors not retried, timeouts retried")
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{0, 50 * time.Millisecond, 100 * time.Millisecond},
+		{3, 400 * time.Millisecond, 800 * time.Millisecond},
+		{30, maxRetryBackoff / 2, maxRetryBackoff},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			if d := retryDelay(tt.attempt, 100*time.Millisecond); d < tt.min || d > tt.max {
+				t.Fatalf("retryDelay(%d) = %v, want within [%v, %v]", tt.attempt, d, tt.min, tt.max)
+			}
+		}
+	}
+}
+
+func TestCrawlRetries(t *testing.T) {
+	mu := sync.Mutex{}
+	requests := map[string]int{}
+	count := func(r *http.Request) int {
+		mu.Lock()
+		defer mu.Unlock()
+		requests[r.Host+r.URL.Path]++
+		return requests[r.Host+r.URL.Path]
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/flaky", func(w http.ResponseWriter, r *http.Request) {
+		if count(r) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("<html><body>flaky@acme.com</body></html>"))
+	})
+	mux.HandleFunc("/down", func(w http.ResponseWriter, r *http.Request) {
+		count(r)
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		count(r)
+		http.NotFound(w, r)
+	})
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	// a host of its own, as rate limits back off the host
+	limited := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count(r)
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	t.Cleanup(limited.Close)
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	hc := newTestHTTPChallenge(ts, "", false)
+	hc.options.IgnoreRobots = true
+	hc.options.Retries = 2
+	hc.options.BackoffMillisecond = 1
+	records := &recordSink{}
+	hc.AddSink(records)
+	hc.CrawlURLsWithWorkerPool(context.Background(), []string{
+		ts.URL + "/flaky", ts.URL + "/down", ts.URL + "/missing", limited.URL + "/", closed.URL + "/",
+	})
+
+	if got := records.Records(); len(got) != 1 || got[0].Value != "flaky@acme.com" {
+		t.Errorf("records = %v, want flaky@acme.com found on the third attempt", got)
+	}
+	failed := append([]FailedURL{}, hc.FailedURLs...)
+	sort.Slice(failed, func(i, j int) bool { return failed[i].Category < failed[j].Category })
+	want := []FailedURL{
+		{URL: closed.URL + "/", Category: FailureConnectionRefused, Attempts: 3},
+		{URL: limited.URL + "/", Category: FailureTooManyRequests, Error: "429 Too Many Requests", Attempts: 3},
+		{URL: ts.URL + "/down", Category: FailureServerError, Error: "500 Internal Server Error", Attempts: 3},
+	}
+	if len(failed) != len(want) {
+		t.Fatalf("FailedURLs = %+v, want %+v", failed, want)
+	}
+	for i, f := range failed {
+		if f.URL != want[i].URL || f.Category != want[i].Category || f.Attempts != want[i].Attempts || (want[i].Error != "" && f.Error != want[i].Error) {
+			t.Errorf("FailedURLs[%d] = %+v, want %+v", i, f, want[i])
+		}
+	}
+	if requests[strings.TrimPrefix(ts.URL, "http://")+"/missing"] != 1 {
+		t.Errorf("requests = %v, want a 404 not retried", requests)
+	}
+	if stats := hc.Stats(); stats.URLsFailed != 3 {
+		t.Errorf("URLsFailed = %d, want 3", stats.URLsFailed)
+	}
+
+	// robots.txt failing alike fails the url, not skips it
+	hc = newTestHTTPChallenge(ts, "", false)
+	hc.CrawlURLsWithWorkerPool(context.Background(), []string{closed.URL + "/"})
+	if len(hc.SkippedURLs) != 0 || len(hc.FailedURLs) != 1 || hc.FailedURLs[0].Category != FailureConnectionRefused {
+		t.Errorf("SkippedURLs = %v, FailedURLs = %v, want %s/ failed as %s", hc.SkippedURLs, hc.FailedURLs, closed.URL, FailureConnectionRefused)
+	}
+}
+
+func TestCrawlBodyStalls(t *testing.T) {
+	mu := sync.Mutex{}
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("<html><body>" + strings.Repeat("stalled@acme.com ", 100)))
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	hc := newTestHTTPChallenge(ts, "", false)
+	hc.options.IgnoreRobots = true
+	hc.options.Retries = 1
+	hc.options.BackoffMillisecond = 1
+	hc.client.Timeout = 200 * time.Millisecond
+	hc.CrawlURLsWithWorkerPool(context.Background(), []string{ts.URL + "/"})
+
+	want := FailedURL{URL: ts.URL + "/", Category: FailureTimeout, Attempts: 2}
+	if len(hc.FailedURLs) != 1 || hc.FailedURLs[0].URL != want.URL || hc.FailedURLs[0].Category != want.Category || hc.FailedURLs[0].Attempts != want.Attempts {
+		t.Errorf("FailedURLs = %+v, want %+v", hc.FailedURLs, want)
+	}
+	if stats := hc.Stats(); stats.URLsFailed != 1 || stats.URLsCrawled != 0 || requests != 2 {
+		t.Errorf("stats = %+v after %d requests, want the url failed after 2", stats, requests)
+	}
+}
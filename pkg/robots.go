@@ -0,0 +1,330 @@
+package pkg
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const UserAgent = "GO kevincobain2000/email_extractor"
+
+const (
+	SkipReasonRobotsDisallowed  = "disallowed by robots.txt"
+	SkipReasonRobotsUnreachable = "robots.txt unreachable"
+)
+
+// robots.txt files larger than this are truncated, as allowed by RFC 9309.
+const robotsMaxBytes = 500 * 1024
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+type robotsGroup struct {
+	agents     []string
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+// RobotsTxt holds the rules of a robots.txt file that apply to one user agent.
+type RobotsTxt struct {
+	rules       []robotsRule
+	disallowAll bool
+	CrawlDelay  time.Duration
+	Sitemaps    []string
+}
+
+// ParseRobotsTxt parses body and keeps only the group that best matches
+// userAgent, falling back to the "*" group.
+func ParseRobotsTxt(body, userAgent string) *RobotsTxt {
+	rt := &RobotsTxt{}
+	var groups []*robotsGroup
+	var current *robotsGroup
+	inAgents := false
+
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				current = &robotsGroup{}
+				groups = append(groups, current)
+				inAgents = true
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+		case "allow", "disallow":
+			inAgents = false
+			if current == nil || value == "" {
+				continue
+			}
+			current.rules = append(current.rules, robotsRule{allow: key == "allow", pattern: value})
+		case "crawl-delay":
+			inAgents = false
+			if current == nil {
+				continue
+			}
+			seconds, err := strconv.ParseFloat(value, 64)
+			if err != nil || seconds < 0 {
+				continue
+			}
+			current.crawlDelay = time.Duration(seconds * float64(time.Second))
+		case "sitemap":
+			// sitemaps are not tied to any group
+			if value != "" {
+				rt.Sitemaps = append(rt.Sitemaps, value)
+			}
+		default:
+			inAgents = false
+		}
+	}
+
+	for _, g := range matchingRobotsGroups(groups, userAgent) {
+		rt.rules = append(rt.rules, g.rules...)
+		if g.crawlDelay > rt.CrawlDelay {
+			rt.CrawlDelay = g.crawlDelay
+		}
+	}
+	return rt
+}
+
+// matchingRobotsGroups returns the groups naming the longest agent token
+// contained in userAgent, or the "*" groups when none does.
+func matchingRobotsGroups(groups []*robotsGroup, userAgent string) []*robotsGroup {
+	ua := strings.ToLower(userAgent)
+	best := ""
+	for _, g := range groups {
+		for _, agent := range g.agents {
+			if agent != "*" && agent != "" && strings.Contains(ua, agent) && len(agent) > len(best) {
+				best = agent
+			}
+		}
+	}
+	if best == "" {
+		best = "*"
+	}
+
+	matched := []*robotsGroup{}
+	for _, g := range groups {
+		if StringInSlice(best, g.agents) {
+			matched = append(matched, g)
+		}
+	}
+	return matched
+}
+
+// Allowed reports whether path (including any query) may be crawled.
+// The longest matching rule wins, and allow wins a tie.
+func (rt *RobotsTxt) Allowed(path string) bool {
+	if rt.disallowAll {
+		return false
+	}
+	if path == "" {
+		path = "/"
+	}
+	if path == "/robots.txt" {
+		return true
+	}
+	allowed := true
+	longest := -1
+	for _, rule := range rt.rules {
+		if !robotsMatch(rule.pattern, path) {
+			continue
+		}
+		if len(rule.pattern) > longest || (len(rule.pattern) == longest && rule.allow) {
+			longest = len(rule.pattern)
+			allowed = rule.allow
+		}
+	}
+	return allowed
+}
+
+// robotsMatch matches path against a robots.txt pattern supporting the
+// "*" wildcard and the "$" end anchor.
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = strings.TrimSuffix(pattern, "$")
+	}
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	pos := len(parts[0])
+	for i, part := range parts[1:] {
+		if anchored && i == len(parts)-2 {
+			return strings.HasSuffix(path[pos:], part)
+		}
+		idx := strings.Index(path[pos:], part)
+		if idx < 0 {
+			return false
+		}
+		pos += idx + len(part)
+	}
+	if anchored {
+		return pos == len(path)
+	}
+	return true
+}
+
+type robotsEntry struct {
+	mu  sync.Mutex
+	txt *RobotsTxt
+	err error
+}
+
+// RobotsError is the error of a robots.txt that could not be requested,
+// after Attempts requests.
+type RobotsError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *RobotsError) Error() string {
+	return "robots.txt: " + e.Err.Error()
+}
+
+func (e *RobotsError) Unwrap() error {
+	return e.Err
+}
+
+// Robots fetches and caches robots.txt per scheme and host.
+type Robots struct {
+	client    *http.Client
+	userAgent string
+	retries   int
+	backoff   time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*robotsEntry
+}
+
+func NewRobots(client *http.Client, userAgent string) *Robots {
+	return &Robots{
+		client:    client,
+		userAgent: userAgent,
+		backoff:   defaultRetryBackoff,
+		hosts:     make(map[string]*robotsEntry),
+	}
+}
+
+// SetRetries retries the requests of robots.txt failing without a response
+// up to retries times, after a backoff doubled for each retry, like pages.
+func (r *Robots) SetRetries(retries int, backoff time.Duration) {
+	r.retries, r.backoff = retries, backoff
+}
+
+// Get returns the robots.txt rules for the host of u, fetching them once.
+// A request failing without a response after its retries, like an unknown
+// host or a refused connection, fails with a *RobotsError, cached for the
+// rest of the run like rules. Only a fetch stopped by ctx is not cached.
+func (r *Robots) Get(ctx context.Context, u string) (*RobotsTxt, error) {
+	base := GetBaseURL(u)
+
+	r.mu.Lock()
+	entry, ok := r.hosts[base]
+	if !ok {
+		entry = &robotsEntry{}
+		r.hosts[base] = entry
+	}
+	r.mu.Unlock()
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if entry.txt == nil && entry.err == nil {
+		txt, err := r.fetchWithRetries(ctx, base)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		entry.txt, entry.err = txt, err
+	}
+	return entry.txt, entry.err
+}
+
+// fetchWithRetries fetches the robots.txt of base, retrying the errors that
+// may pass.
+func (r *Robots) fetchWithRetries(ctx context.Context, base string) (*RobotsTxt, error) {
+	for attempt := 0; ; attempt++ {
+		txt, err := r.fetch(ctx, base)
+		if err == nil {
+			return txt, nil
+		}
+		if attempt >= r.retries || !retryable(classifyError(err), err) {
+			return nil, &RobotsError{Attempts: attempt + 1, Err: err}
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(retryDelay(attempt, r.backoff)):
+		}
+	}
+}
+
+func (r *Robots) fetch(ctx context.Context, base string) (*RobotsTxt, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/robots.txt", nil)
+	if err != nil {
+		return &RobotsTxt{}, nil
+	}
+	req.Header.Set("User-Agent", r.userAgent)
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 500:
+		// server errors mean complete disallow, see RFC 9309 section 2.3.1.4
+		return &RobotsTxt{disallowAll: true}, nil
+	case resp.StatusCode >= 400:
+		// no robots.txt, everything is allowed
+		return &RobotsTxt{}, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, robotsMaxBytes))
+	if err != nil {
+		return nil, err
+	}
+	return ParseRobotsTxt(string(body), r.userAgent), nil
+}
+
+// Allowed reports whether u may be crawled and, if not, why. It fails
+// when robots.txt could not be requested, see Get.
+func (r *Robots) Allowed(ctx context.Context, u string) (bool, string, error) {
+	parsedURL, err := url.Parse(u)
+	if err != nil {
+		return true, "", nil
+	}
+	rt, err := r.Get(ctx, u)
+	if err != nil {
+		return false, "", err
+	}
+	if rt.disallowAll {
+		return false, SkipReasonRobotsUnreachable, nil
+	}
+	path := parsedURL.EscapedPath()
+	if parsedURL.RawQuery != "" {
+		path += "?" + parsedURL.RawQuery
+	}
+	if !rt.Allowed(path) {
+		return false, SkipReasonRobotsDisallowed, nil
+	}
+	return true, "", nil
+}
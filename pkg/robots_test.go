@@ -0,0 +1,189 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRobotsTxtAllowed(t *testing.T) {
+	body := `
+# comment
+User-agent: *
+Disallow: /private
+Allow: /private/contact
+Crawl-delay: 2
+
+User-agent: otherbot
+Disallow: /
+
+User-agent: email_extractor
+Disallow: /team/*.php$
+Disallow: /search?
+Crawl-delay: 0.5
+
+Sitemap: https://example.com/sitemap.xml
+`
+	rt := ParseRobotsTxt(body, UserAgent)
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/", true},
+		{"/private", true},
+		{"/team/list.php", false},
+		{"/team/list.php?x=1", true},
+		{"/search?q=a", false},
+		{"/search", true},
+		{"/robots.txt", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := rt.Allowed(tt.path); got != tt.want {
+				t.Errorf("Allowed(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	if rt.CrawlDelay != 500*time.Millisecond {
+		t.Errorf("CrawlDelay = %v, want %v", rt.CrawlDelay, 500*time.Millisecond)
+	}
+	if !IsEqualSlice(rt.Sitemaps, []string{"https://example.com/sitemap.xml"}) {
+		t.Errorf("Sitemaps = %v", rt.Sitemaps)
+	}
+}
+
+func TestRobotsTxtFallbackGroup(t *testing.T) {
+	body := `
+User-agent: otherbot
+User-agent: *
+Disallow: /private
+Allow: /private/contact
+`
+	rt := ParseRobotsTxt(body, UserAgent)
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/about", true},
+		{"/private/team", false},
+		{"/private/contact", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := rt.Allowed(tt.path); got != tt.want {
+				t.Errorf("Allowed(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRobotsAllowed(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "User-agent: *\nDisallow: /private\n")
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer broken.Close()
+
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+
+	r := NewRobots(&http.Client{Timeout: time.Second}, UserAgent)
+	tests := []struct {
+		url        string
+		wantOK     bool
+		wantReason string
+	}{
+		{ts.URL + "/about", true, ""},
+		{ts.URL + "/private/team", false, SkipReasonRobotsDisallowed},
+		{broken.URL + "/about", false, SkipReasonRobotsUnreachable},
+		{missing.URL + "/private", true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			ok, reason, err := r.Allowed(context.Background(), tt.url)
+			if ok != tt.wantOK || reason != tt.wantReason || err != nil {
+				t.Errorf("Allowed(%q) = %v, %q, %v, want %v, %q", tt.url, ok, reason, err, tt.wantOK, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestRobotsTransportError(t *testing.T) {
+	mu := sync.Mutex{}
+	requests := map[string]int{}
+	// hang-up/ hangs up the first request, down/ all of them, slow/ stalls
+	// the first one
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.Host]++
+		n := requests[r.Host]
+		mu.Unlock()
+		switch {
+		case strings.HasPrefix(r.Host, "hang-up.") && n == 1, strings.HasPrefix(r.Host, "down."):
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		case strings.HasPrefix(r.Host, "slow.") && n == 1:
+			<-r.Context().Done()
+			return
+		}
+		fmt.Fprint(w, "User-agent: *\nDisallow: /private\n")
+	}))
+	defer ts.Close()
+	// hosts of their own, all served by ts
+	port := ts.URL[strings.LastIndex(ts.URL, ":"):]
+	client := ts.Client()
+	client.Timeout = 5 * time.Second
+	client.Transport.(*http.Transport).DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, ts.Listener.Addr().String())
+	}
+	r := NewRobots(client, UserAgent)
+	r.SetRetries(1, time.Millisecond)
+	ctx := context.Background()
+
+	// retried
+	if ok, reason, err := r.Allowed(ctx, "http://hang-up.test"+port+"/private"); ok || reason != SkipReasonRobotsDisallowed || err != nil {
+		t.Errorf("Allowed(hang-up) = %v, %q, %v, want disallowed once retried", ok, reason, err)
+	}
+
+	// failed after the retries, for the rest of the run
+	for i := 0; i < 2; i++ {
+		_, _, err := r.Allowed(ctx, "http://down.test"+port+"/about")
+		var robotsErr *RobotsError
+		if !errors.As(err, &robotsErr) || robotsErr.Attempts != 2 || classifyError(err) != FailureConnection {
+			t.Errorf("Allowed(down) = %v, want a connection error after 2 attempts", err)
+		}
+	}
+	mu.Lock()
+	if requests["down.test"+port] != 2 {
+		t.Errorf("requests = %v, want the failure of down.test cached", requests)
+	}
+	mu.Unlock()
+
+	// stopped by ctx, and not cached
+	cancelled, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, _, err := r.Allowed(cancelled, "http://slow.test"+port+"/about"); !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > time.Second {
+		t.Errorf("Allowed(slow) = %v after %v, want stopped by ctx", err, time.Since(start))
+	}
+	if ok, _, err := r.Allowed(ctx, "http://slow.test"+port+"/about"); !ok || err != nil {
+		t.Errorf("Allowed(slow) = %v, %v, want allowed once fetched again", ok, err)
+	}
+}
@@ -0,0 +1,372 @@
+package pkg
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	schedulerMinBackoff = time.Second
+	schedulerMaxBackoff = 5 * time.Minute
+)
+
+type hostState struct {
+	tokens     float64
+	refilledAt time.Time
+	// no request may start before notBefore, set by the request spacing,
+	// Crawl-delay and backoff
+	notBefore  time.Time
+	crawlDelay time.Duration
+	backoff    time.Duration
+	inFlight   int
+	// prepared is set once the Crawl-delay of the host is known, before
+	// its first request
+	prepared  bool
+	preparing bool
+}
+
+// HostScheduler keeps per host politeness: a token bucket of requests per
+// second, a cap on in-flight requests, a minimum spacing between requests
+// (from -sleep or Crawl-delay), and a backoff for hosts answering 429 or
+// 503. It also holds the frontier of urls to crawl, handing out urls whose
+// host is ready first.
+type HostScheduler struct {
+	rate        float64
+	maxInFlight int
+	spacing     time.Duration
+	// crawlDelayOf returns the Crawl-delay of the host of a url
+	crawlDelayOf func(ctx context.Context, u string) time.Duration
+
+	mu      sync.Mutex
+	hosts   map[string]*hostState
+	changed chan struct{}
+	// held counts the slots taken per url, not given back yet
+	held map[string]int
+
+	queue  map[string][]string
+	order  []string
+	active int
+	closed bool
+}
+
+// NewHostScheduler returns a scheduler allowing rate requests per second
+// and maxInFlight concurrent requests per host, with at least spacing
+// between requests to the same host. Zero values mean no limit.
+func NewHostScheduler(rate float64, maxInFlight int, spacing time.Duration) *HostScheduler {
+	return &HostScheduler{
+		rate:        rate,
+		maxInFlight: maxInFlight,
+		spacing:     spacing,
+		hosts:       make(map[string]*hostState),
+		changed:     make(chan struct{}),
+		held:        make(map[string]int),
+		queue:       make(map[string][]string),
+	}
+}
+
+func hostOf(u string) string {
+	parsedURL, err := url.Parse(u)
+	if err != nil {
+		return ""
+	}
+	return parsedURL.Host
+}
+
+func (s *HostScheduler) host(host string) *hostState {
+	h, ok := s.hosts[host]
+	if !ok {
+		h = &hostState{tokens: s.burst(), prepared: s.crawlDelayOf == nil}
+		s.hosts[host] = h
+	}
+	return h
+}
+
+func (s *HostScheduler) burst() float64 {
+	return max(1, s.rate)
+}
+
+// broadcast wakes up everyone waiting for a host or url. Callers hold s.mu.
+func (s *HostScheduler) broadcast() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// ready reports whether a request to h may start now, or else how long to
+// wait before it may, with 0 meaning until a request finishes.
+func (s *HostScheduler) ready(h *hostState, now time.Time) (bool, time.Duration) {
+	if !h.prepared {
+		return false, 0
+	}
+	if s.maxInFlight > 0 && h.inFlight >= s.maxInFlight {
+		return false, 0
+	}
+	if now.Before(h.notBefore) {
+		return false, h.notBefore.Sub(now)
+	}
+	if s.rate > 0 {
+		if !h.refilledAt.IsZero() {
+			h.tokens = min(s.burst(), h.tokens+now.Sub(h.refilledAt).Seconds()*s.rate)
+		}
+		h.refilledAt = now
+		if h.tokens < 1 {
+			return false, time.Duration((1 - h.tokens) / s.rate * float64(time.Second))
+		}
+	}
+	return true, 0
+}
+
+func (s *HostScheduler) take(h *hostState, now time.Time) {
+	if s.rate > 0 {
+		h.tokens--
+	}
+	h.inFlight++
+	h.notBefore = now.Add(max(s.spacing, h.crawlDelay))
+}
+
+// wait blocks until the scheduler changes or d passes, d of 0 meaning only
+// the former, or ctx is done.
+func wait(ctx context.Context, changed chan struct{}, d time.Duration) error {
+	var timeout <-chan time.Time
+	if d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-changed:
+	case <-timeout:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
+
+// Acquire blocks until a request to the host of u may start and takes a
+// slot for it, to be given back with Release. It fails only if ctx is done
+// first.
+func (s *HostScheduler) Acquire(ctx context.Context, u string) error {
+	for {
+		s.mu.Lock()
+		h := s.host(hostOf(u))
+		if !h.prepared && !h.preparing {
+			s.prepare(ctx, h, u)
+			s.mu.Unlock()
+			continue
+		}
+		now := time.Now()
+		ok, d := s.ready(h, now)
+		if ok {
+			s.take(h, now)
+			s.held[u]++
+			s.mu.Unlock()
+			return nil
+		}
+		changed := s.changed
+		s.mu.Unlock()
+		if err := wait(ctx, changed, d); err != nil {
+			return err
+		}
+	}
+}
+
+// Wait blocks for at least d, and until a further request to the host of u
+// may start for a caller holding a slot of it, like a retry, after the
+// spacing, Crawl-delay and backoff of the host. It fails only if ctx is done
+// first.
+func (s *HostScheduler) Wait(ctx context.Context, u string, d time.Duration) error {
+	if d > 0 {
+		if err := wait(ctx, nil, d); err != nil {
+			return err
+		}
+	}
+	for {
+		s.mu.Lock()
+		h := s.host(hostOf(u))
+		now := time.Now()
+		// the slot held is not counted against maxInFlight, take counts it back
+		h.inFlight--
+		ok, d := s.ready(h, now)
+		if ok {
+			s.take(h, now)
+			s.mu.Unlock()
+			return nil
+		}
+		h.inFlight++
+		changed := s.changed
+		s.mu.Unlock()
+		if err := wait(ctx, changed, d); err != nil {
+			return err
+		}
+	}
+}
+
+// Release gives back the slot of the host of u taken by Acquire or Next.
+// Giving it back again, like Done after Release, does nothing.
+func (s *HostScheduler) Release(u string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.held[u] == 0 {
+		return
+	}
+	s.held[u]--
+	if s.held[u] == 0 {
+		delete(s.held, u)
+	}
+	h := s.host(hostOf(u))
+	if h.inFlight > 0 {
+		h.inFlight--
+	}
+	s.broadcast()
+}
+
+// Report adapts the pace of the host of u to a response: 429 and 503 back
+// the host off, for as long as Retry-After asks when it is given, other
+// responses ease a previous backoff.
+func (s *HostScheduler) Report(u string, status int, header http.Header) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h := s.host(hostOf(u))
+	now := time.Now()
+
+	if status != http.StatusTooManyRequests && status != http.StatusServiceUnavailable {
+		h.backoff /= 2
+		if h.backoff < schedulerMinBackoff {
+			h.backoff = 0
+		}
+		return
+	}
+
+	h.backoff = min(max(schedulerMinBackoff, h.backoff*2), schedulerMaxBackoff)
+	delay := h.backoff
+	if retryAfter, ok := ParseRetryAfter(header.Get("Retry-After"), now); ok {
+		delay = min(retryAfter, schedulerMaxBackoff)
+	}
+	if next := now.Add(delay); next.After(h.notBefore) {
+		h.notBefore = next
+	}
+	s.broadcast()
+}
+
+// SetCrawlDelayFunc sets fn to return the minimum spacing between requests
+// to the host of a url asked for by its robots.txt. It is called once per
+// host, before a first slot of the host is handed out.
+func (s *HostScheduler) SetCrawlDelayFunc(fn func(ctx context.Context, u string) time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.crawlDelayOf = fn
+}
+
+// prepare sets the Crawl-delay of h, the host of u, calling crawlDelayOf
+// without holding s.mu, which callers hold. Others wait for h meanwhile.
+func (s *HostScheduler) prepare(ctx context.Context, h *hostState, u string) {
+	h.preparing = true
+	s.mu.Unlock()
+	d := s.crawlDelayOf(ctx, u)
+	s.mu.Lock()
+	h.crawlDelay = d
+	h.prepared, h.preparing = true, false
+	s.broadcast()
+}
+
+// ParseRetryAfter parses a Retry-After header given in seconds or as an
+// HTTP date.
+func ParseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(0, date.Sub(now)), true
+	}
+	return 0, false
+}
+
+// Push adds u to the frontier.
+func (s *HostScheduler) Push(u string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	host := hostOf(u)
+	if len(s.queue[host]) == 0 {
+		s.order = append(s.order, host)
+	}
+	s.queue[host] = append(s.queue[host], u)
+	s.broadcast()
+}
+
+// Next takes the next url of the frontier whose host is ready, blocking
+// until there is one, together with a slot of its host. Each url must be
+// given back with Done, after pushing the urls found on it. Next returns
+// false once the frontier is empty and no url is being crawled, the
+// scheduler is closed, or ctx is done.
+func (s *HostScheduler) Next(ctx context.Context) (string, bool) {
+next:
+	for {
+		s.mu.Lock()
+		if s.closed || (len(s.order) == 0 && s.active == 0) || ctx.Err() != nil {
+			s.mu.Unlock()
+			return "", false
+		}
+
+		now := time.Now()
+		var soonest time.Duration
+		for i, host := range s.order {
+			h := s.host(host)
+			if !h.prepared && !h.preparing {
+				s.prepare(ctx, h, s.queue[host][0])
+				s.mu.Unlock()
+				continue next
+			}
+			ok, d := s.ready(h, now)
+			if !ok {
+				if d > 0 && (soonest == 0 || d < soonest) {
+					soonest = d
+				}
+				continue
+			}
+			u := s.queue[host][0]
+			s.queue[host] = s.queue[host][1:]
+			// take turns between hosts
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			if len(s.queue[host]) > 0 {
+				s.order = append(s.order, host)
+			} else {
+				delete(s.queue, host)
+			}
+			s.take(h, now)
+			s.held[u]++
+			s.active++
+			s.mu.Unlock()
+			return u, true
+		}
+
+		changed := s.changed
+		s.mu.Unlock()
+		if err := wait(ctx, changed, soonest); err != nil {
+			return "", false
+		}
+	}
+}
+
+// Done gives back u taken by Next, with the slot of its host unless released
+// already.
+func (s *HostScheduler) Done(u string) {
+	s.mu.Lock()
+	s.active--
+	s.broadcast()
+	s.mu.Unlock()
+	s.Release(u)
+}
+
+// Close stops Next from handing out urls.
+func (s *HostScheduler) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	s.broadcast()
+}
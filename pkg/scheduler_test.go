@@ -0,0 +1,233 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"", 0, false},
+		{"120", 2 * time.Minute, true},
+		{"Mon, 01 Jan 2024 00:00:30 GMT", 30 * time.Second, true},
+		{"Sun, 31 Dec 2023 00:00:00 GMT", 0, true},
+		{"soon", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, ok := ParseRetryAfter(tt.value, now)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("ParseRetryAfter(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestHostSchedulerNextPrefersReadyHost(t *testing.T) {
+	s := NewHostScheduler(0, 1, 0)
+	s.Push("https://a.example.com/1")
+	s.Push("https://a.example.com/2")
+	s.Push("https://b.example.com/1")
+
+	first, _ := s.Next(context.Background())
+	second, _ := s.Next(context.Background())
+	if first != "https://a.example.com/1" || second != "https://b.example.com/1" {
+		t.Fatalf("Next() = %s, %s, want a/1 then b/1 while a is busy", first, second)
+	}
+
+	got := make(chan string)
+	go func() {
+		u, _ := s.Next(context.Background())
+		got <- u
+	}()
+	select {
+	case u := <-got:
+		t.Fatalf("Next() = %s while both hosts are busy", u)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	s.Done(first)
+	if u := <-got; u != "https://a.example.com/2" {
+		t.Errorf("Next() = %s, want a/2", u)
+	}
+}
+
+func TestHostSchedulerNextStopsWhenDrained(t *testing.T) {
+	s := NewHostScheduler(0, 0, 0)
+	s.Push("https://example.com/")
+
+	u, ok := s.Next(context.Background())
+	if !ok {
+		t.Fatal("Next() = false with a queued url")
+	}
+
+	done := make(chan bool)
+	go func() {
+		_, ok := s.Next(context.Background())
+		done <- ok
+	}()
+	// a url being crawled may still push more
+	s.Push("https://example.com/about")
+	if ok := <-done; !ok {
+		t.Fatal("Next() = false with a pushed url")
+	}
+	s.Done(u)
+	s.Done("https://example.com/about")
+
+	if _, ok := s.Next(context.Background()); ok {
+		t.Error("Next() = true with an empty frontier")
+	}
+}
+
+func TestHostSchedulerBackoff(t *testing.T) {
+	s := NewHostScheduler(0, 0, 0)
+	u := "https://example.com/"
+
+	header := http.Header{}
+	header.Set("Retry-After", "1")
+	s.Report(u, http.StatusTooManyRequests, header)
+
+	start := time.Now()
+	if err := s.Acquire(context.Background(), u); err != nil {
+		t.Fatal(err)
+	}
+	s.Release(u)
+	if elapsed := time.Since(start); elapsed < 900*time.Millisecond {
+		t.Errorf("Acquire() after Retry-After: 1 took %v", elapsed)
+	}
+}
+
+func TestHostSchedulerWait(t *testing.T) {
+	s := NewHostScheduler(0, 1, 0)
+	u := "https://example.com/"
+	if err := s.Acquire(context.Background(), u); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Release(u)
+
+	// the slot held does not hold back its own retry
+	start := time.Now()
+	if err := s.Wait(context.Background(), u, 10*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 10*time.Millisecond || elapsed > 500*time.Millisecond {
+		t.Errorf("Wait(10ms) took %v", elapsed)
+	}
+
+	header := http.Header{}
+	header.Set("Retry-After", "1")
+	s.Report(u, http.StatusTooManyRequests, header)
+	start = time.Now()
+	if err := s.Wait(context.Background(), u, 10*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 900*time.Millisecond {
+		t.Errorf("Wait(10ms) after Retry-After: 1 took %v", elapsed)
+	}
+}
+
+func TestHostSchedulerReleaseTwice(t *testing.T) {
+	s := NewHostScheduler(0, 1, 0)
+	if err := s.Acquire(context.Background(), "https://example.com/1"); err != nil {
+		t.Fatal(err)
+	}
+	s.Release("https://example.com/1")
+	if err := s.Acquire(context.Background(), "https://example.com/2"); err != nil {
+		t.Fatal(err)
+	}
+	// the slot of /1 was given back already, the one of /2 is still held
+	s.Release("https://example.com/1")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := s.Acquire(ctx, "https://example.com/3"); err == nil {
+		t.Error("Acquire() = nil with the slot of the host held")
+	}
+}
+
+func TestHostSchedulerRate(t *testing.T) {
+	s := NewHostScheduler(20, 0, 0)
+	u := "https://example.com/"
+
+	start := time.Now()
+	// the first 20 use up the burst, the next 10 come at 20 per second
+	for i := 0; i < 30; i++ {
+		if err := s.Acquire(context.Background(), u); err != nil {
+			t.Fatal(err)
+		}
+		s.Release(u)
+	}
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("30 requests at 20 per second took %v", elapsed)
+	}
+}
+
+func TestHostSchedulerNextCancelled(t *testing.T) {
+	s := NewHostScheduler(0, 1, 0)
+	s.Push("https://example.com/1")
+	s.Push("https://example.com/2")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if _, ok := s.Next(ctx); !ok {
+		t.Fatal("Next() = false with a queued url")
+	}
+
+	done := make(chan bool)
+	go func() {
+		_, ok := s.Next(ctx)
+		done <- ok
+	}()
+	cancel()
+	if ok := <-done; ok {
+		t.Error("Next() = true after cancel")
+	}
+	if err := s.Acquire(ctx, "https://example.com/2"); err == nil {
+		t.Error("Acquire() = nil after cancel")
+	}
+}
+
+func TestCrawlDelayFirstRequests(t *testing.T) {
+	mu := sync.Mutex{}
+	starts := []time.Time{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "User-agent: *\nCrawl-delay: 0.3\n")
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		starts = append(starts, time.Now())
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<html><body>page</body></html>")
+	})
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+
+	hc := newTestHTTPChallenge(ts, "", false)
+	urls := []string{}
+	for i := 0; i < 4; i++ {
+		urls = append(urls, fmt.Sprintf("%s/page/%d", ts.URL, i))
+	}
+	hc.CrawlURLsWithWorkerPool(context.Background(), urls)
+
+	if len(starts) != len(urls) {
+		t.Fatalf("requested %d pages, want %d", len(starts), len(urls))
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+	for i := 1; i < len(starts); i++ {
+		// a little less than the delay, for the clock of the server
+		if gap := starts[i].Sub(starts[i-1]); gap < 250*time.Millisecond {
+			t.Errorf("request %d started %v after the previous one, want the Crawl-delay of 300ms", i, gap)
+		}
+	}
+}
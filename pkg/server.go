@@ -0,0 +1,215 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/net/websocket"
+)
+
+// NewServer returns the HTTP API to run crawl jobs:
+//
+//	POST   /jobs             start a job, with CrawlOptions as json
+//	GET    /jobs             list jobs
+//	GET    /jobs/:id         status and counters of a job
+//	GET    /jobs/:id/emails  email records found by a job
+//	GET    /jobs/:id/records records of all types found by a job
+//	GET    /jobs/:id/events  events of a job as server-sent events
+//	GET    /jobs/:id/ws      events of a job over a websocket
+//	DELETE /jobs/:id         cancel a job
+//
+// Event streams start after the event given by the Last-Event-ID header or
+// the last_event_id query param, and end once the job is completed.
+func NewServer(jobs *Jobs) *echo.Echo {
+	e := echo.New()
+	e.HideBanner = true
+	e.HTTPErrorHandler = jsonErrorHandler
+
+	e.POST("/jobs", func(c echo.Context) error {
+		opt := DefaultCrawlOptions()
+		dec := json.NewDecoder(c.Request().Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&opt); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid options: "+err.Error())
+		}
+		job, err := jobs.Start(opt)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return responseJSON(c, http.StatusCreated, job.Info())
+	})
+	e.GET("/jobs", func(c echo.Context) error {
+		infos := []JobInfo{}
+		for _, job := range jobs.List() {
+			infos = append(infos, job.Info())
+		}
+		return responseJSON(c, http.StatusOK, infos)
+	})
+	e.GET("/jobs/:id", func(c echo.Context) error {
+		job, err := jobs.Get(c.Param("id"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		return responseJSON(c, http.StatusOK, job.Info())
+	})
+	e.GET("/jobs/:id/emails", func(c echo.Context) error {
+		job, err := jobs.Get(c.Param("id"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		return responseJSON(c, http.StatusOK, job.Emails())
+	})
+	e.GET("/jobs/:id/records", func(c echo.Context) error {
+		job, err := jobs.Get(c.Param("id"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		return responseJSON(c, http.StatusOK, job.Records())
+	})
+	e.GET("/jobs/:id/events", func(c echo.Context) error {
+		job, err := jobs.Get(c.Param("id"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		lastID, err := lastEventID(c)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		header := c.Response().Header()
+		header.Set(echo.HeaderContentType, "text/event-stream")
+		header.Set("Cache-Control", "no-cache")
+		header.Set("X-Accel-Buffering", "no")
+		c.Response().WriteHeader(http.StatusOK)
+		c.Response().Flush()
+
+		w := c.Response()
+		return streamEvents(c.Request().Context(), job.Events(), lastID, func(e Event) error {
+			data, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data); err != nil {
+				return err
+			}
+			w.Flush()
+			return nil
+		}, func() error {
+			// keeps proxies from closing an idle stream
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return err
+			}
+			w.Flush()
+			return nil
+		})
+	})
+	e.GET("/jobs/:id/ws", func(c echo.Context) error {
+		job, err := jobs.Get(c.Param("id"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		lastID, err := lastEventID(c)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		// no Handshake, so clients other than browsers need no Origin
+		websocket.Server{Handler: func(ws *websocket.Conn) {
+			defer ws.Close()
+			err := streamEvents(c.Request().Context(), job.Events(), lastID, func(e Event) error {
+				return websocket.JSON.Send(ws, e)
+			}, func() error {
+				ws.PayloadType = websocket.PingFrame
+				defer func() { ws.PayloadType = websocket.TextFrame }()
+				_, err := ws.Write(nil)
+				return err
+			})
+			if err != nil {
+				c.Logger().Debug(err)
+			}
+		}}.ServeHTTP(c.Response(), c.Request())
+		return nil
+	})
+	e.DELETE("/jobs/:id", func(c echo.Context) error {
+		job, err := jobs.Get(c.Param("id"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		job.Cancel()
+		return responseJSON(c, http.StatusAccepted, job.Info())
+	})
+	return e
+}
+
+const eventsKeepAlive = 15 * time.Second
+
+// streamEvents sends the events of log after lastID, waiting for new ones
+// until the log is complete or ctx is done, and pings while idle.
+func streamEvents(ctx context.Context, log *EventLog, lastID int64, send func(Event) error, ping func() error) error {
+	ticker := time.NewTicker(eventsKeepAlive)
+	defer ticker.Stop()
+	for {
+		events, changed, closed := log.Since(lastID)
+		for _, e := range events {
+			if err := send(e); err != nil {
+				return err
+			}
+			lastID = e.ID
+		}
+		if closed {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ticker.C:
+			if err := ping(); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func lastEventID(c echo.Context) (int64, error) {
+	value := c.Request().Header.Get("Last-Event-ID")
+	if value == "" {
+		value = c.QueryParam("last_event_id")
+	}
+	if value == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid last event id %q", value)
+	}
+	return id, nil
+}
+
+func responseJSON(c echo.Context, code int, i interface{}) error {
+	SetHeadersResponseJSON(c.Response().Header())
+	return c.JSON(code, i)
+}
+
+func jsonErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+	code := http.StatusInternalServerError
+	message := err.Error()
+	var he *echo.HTTPError
+	if errors.As(err, &he) {
+		code = he.Code
+		if m, ok := he.Message.(string); ok {
+			message = m
+		}
+	}
+	if err := responseJSON(c, code, map[string]string{"error": message}); err != nil {
+		c.Logger().Error(err)
+	}
+}
@@ -0,0 +1,237 @@
+package pkg
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/net/websocket"
+)
+
+func serve(t *testing.T, e *echo.Echo, method, path, body string, v interface{}) int {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if v != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("%s %s: %v: %s", method, path, err, rec.Body.String())
+		}
+	}
+	return rec.Code
+}
+
+func TestServerJobs(t *testing.T) {
+	site := newTestSite(t)
+	jobs := NewJobs()
+	e := NewServer(jobs)
+
+	var info JobInfo
+	body := fmt.Sprintf(`{"url": %q, "max_workers": 4}`, site.URL+"/page/0")
+	if code := serve(t, e, http.MethodPost, "/jobs", body, &info); code != http.StatusCreated {
+		t.Fatalf("POST /jobs = %d", code)
+	}
+	if info.Status != JobRunning || info.Options.LimitUrls != DefaultCrawlOptions().LimitUrls {
+		t.Errorf("POST /jobs = %+v", info)
+	}
+
+	job, err := jobs.Get(info.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.Wait()
+
+	if code := serve(t, e, http.MethodGet, "/jobs/"+info.ID, "", &info); code != http.StatusOK {
+		t.Fatalf("GET /jobs/%s = %d", info.ID, code)
+	}
+	if info.Status != JobDone || info.Stats.URLsCrawled != testSitePages || info.Stats.Emails != testSitePages {
+		t.Errorf("GET /jobs/%s = %+v", info.ID, info)
+	}
+
+	var records []Record
+	if code := serve(t, e, http.MethodGet, "/jobs/"+info.ID+"/emails", "", &records); code != http.StatusOK {
+		t.Fatalf("GET /jobs/%s/emails = %d", info.ID, code)
+	}
+	if len(records) != testSitePages || records[0].SourceURL == "" {
+		t.Errorf("GET /jobs/%s/emails = %d records", info.ID, len(records))
+	}
+}
+
+func TestServerCancelJob(t *testing.T) {
+	// a site that never answers until the test ends
+	release := make(chan struct{})
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer site.Close()
+	defer close(release)
+
+	jobs := NewJobs()
+	e := NewServer(jobs)
+
+	var info JobInfo
+	body := fmt.Sprintf(`{"url": %q, "ignore_robots": true, "timeout": 500}`, site.URL)
+	if code := serve(t, e, http.MethodPost, "/jobs", body, &info); code != http.StatusCreated {
+		t.Fatalf("POST /jobs = %d", code)
+	}
+	if code := serve(t, e, http.MethodDelete, "/jobs/"+info.ID, "", &info); code != http.StatusAccepted {
+		t.Fatalf("DELETE /jobs/%s = %d", info.ID, code)
+	}
+
+	job, err := jobs.Get(info.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-job.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("job still running after DELETE")
+	}
+	if got := job.Info().Status; got != JobCancelled {
+		t.Errorf("status = %s, want %s", got, JobCancelled)
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	e := NewServer(NewJobs())
+
+	var res map[string]string
+	if code := serve(t, e, http.MethodGet, "/jobs/nope", "", &res); code != http.StatusNotFound || res["error"] != ErrJobNotFound.Error() {
+		t.Errorf("GET /jobs/nope = %d %v", code, res)
+	}
+	if code := serve(t, e, http.MethodPost, "/jobs", `{"depht": 1}`, &res); code != http.StatusBadRequest {
+		t.Errorf("POST /jobs with an unknown option = %d %v", code, res)
+	}
+	if code := serve(t, e, http.MethodPost, "/jobs", `{}`, &res); code != http.StatusBadRequest {
+		t.Errorf("POST /jobs without url = %d %v", code, res)
+	}
+	for _, body := range []string{
+		`{"url": "acme.com", "deobfuscate": ["x"]}`,
+		`{"url": "acme.com", "extract": ["faxes"]}`,
+		`{"url": "acme.com", "documents": ["rtf"]}`,
+		`{"url": "acme.com", "exclude": ["spam"]}`,
+	} {
+		res = nil
+		if code := serve(t, e, http.MethodPost, "/jobs", body, &res); code != http.StatusBadRequest || !strings.Contains(res["error"], "unknown") {
+			t.Errorf("POST /jobs %s = %d %v, want 400 with the invalid option", body, code, res)
+		}
+	}
+	for _, body := range []string{
+		`{"url": "acme.com", "max_workers": 100000000}`,
+		`{"url": "acme.com", "max_workers": 0}`,
+		`{"url": "acme.com", "timeout": 0}`,
+		`{"url": "acme.com", "timeout": -1}`,
+		`{"url": "acme.com", "verify_workers": 0}`,
+		`{"url": "acme.com", "verify_workers": 100000}`,
+		`{"url": "acme.com", "max_body_bytes": -1}`,
+		`{"url": "acme.com", "max_document_bytes": 0}`,
+		`{"url": "acme.com", "max_document_bytes": 100000000000}`,
+	} {
+		res = nil
+		if code := serve(t, e, http.MethodPost, "/jobs", body, &res); code != http.StatusBadRequest || !strings.Contains(res["error"], "must be between") {
+			t.Errorf("POST /jobs %s = %d %v, want 400 with the bounds", body, code, res)
+		}
+	}
+}
+
+// readSSE reads the events of a server-sent events stream until it ends.
+func readSSE(t *testing.T, body io.Reader) []Event {
+	t.Helper()
+	events := []Event{}
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal([]byte(data), &e); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+func TestServerJobEvents(t *testing.T) {
+	site := newTestSite(t)
+	jobs := NewJobs()
+	api := httptest.NewServer(NewServer(jobs))
+	defer api.Close()
+
+	res, err := http.Post(api.URL+"/jobs", echo.MIMEApplicationJSON, strings.NewReader(fmt.Sprintf(`{"url": %q}`, site.URL+"/page/0")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var info JobInfo
+	err = json.NewDecoder(res.Body).Decode(&info)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// subscribe while the job runs
+	res, err = http.Get(api.URL + "/jobs/" + info.ID + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := readSSE(t, res.Body)
+	res.Body.Close()
+
+	count := map[string]int{}
+	for i, e := range events {
+		if e.ID != int64(i+1) {
+			t.Fatalf("event %d has id %d", i, e.ID)
+		}
+		count[e.Type]++
+	}
+	if count[EventPageStarted] != testSitePages || count[EventPageFetched] != testSitePages || count[EventEmailFound] != testSitePages {
+		t.Errorf("event counts = %v", count)
+	}
+	last := events[len(events)-1]
+	if last.Type != EventCompleted || last.JobStatus != JobDone || last.Stats.Emails != testSitePages {
+		t.Errorf("last event = %+v", last)
+	}
+
+	// reconnect after the tenth event
+	req, err := http.NewRequest(http.MethodGet, api.URL+"/jobs/"+info.ID+"/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Last-Event-ID", "10")
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed := readSSE(t, res.Body)
+	res.Body.Close()
+	if len(replayed) != len(events)-10 || replayed[0].ID != 11 {
+		t.Errorf("replayed %d events from %d, want %d from 11", len(replayed), replayed[0].ID, len(events)-10)
+	}
+
+	// the same events over a websocket
+	ws, err := websocket.Dial(strings.Replace(api.URL, "http", "ws", 1)+"/jobs/"+info.ID+"/ws?last_event_id=10", "", api.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	n := 0
+	for {
+		var e Event
+		if err := websocket.JSON.Receive(ws, &e); err != nil {
+			break
+		}
+		n++
+	}
+	if n != len(replayed) {
+		t.Errorf("websocket sent %d events, want %d", n, len(replayed))
+	}
+}
@@ -0,0 +1,134 @@
+package pkg
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gookit/color"
+)
+
+const (
+	// sitemaps may not be larger than 50MB uncompressed, see sitemaps.org
+	sitemapMaxBytes = 50 * 1024 * 1024
+	// stops runaway or looping sitemap indexes
+	sitemapMaxFiles = 100
+)
+
+type sitemapLoc struct {
+	Loc string `xml:"loc"`
+}
+
+// sitemapXML decodes both a <urlset> and a <sitemapindex>.
+type sitemapXML struct {
+	URLs     []sitemapLoc `xml:"url"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+// ParseSitemap returns the page urls and the nested sitemap urls listed in
+// body, which may be gzipped.
+func ParseSitemap(body []byte) ([]string, []string, error) {
+	if bytes.HasPrefix(body, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, nil, err
+		}
+		defer zr.Close()
+		body, err = io.ReadAll(io.LimitReader(zr, sitemapMaxBytes))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var sm sitemapXML
+	if err := xml.Unmarshal(body, &sm); err != nil {
+		return nil, nil, err
+	}
+	urls := []string{}
+	for _, u := range sm.URLs {
+		if loc := strings.TrimSpace(u.Loc); loc != "" {
+			urls = append(urls, loc)
+		}
+	}
+	sitemaps := []string{}
+	for _, s := range sm.Sitemaps {
+		if loc := strings.TrimSpace(s.Loc); loc != "" {
+			sitemaps = append(sitemaps, loc)
+		}
+	}
+	return urls, sitemaps, nil
+}
+
+// SitemapURLs returns the urls listed in the sitemaps of the host of url,
+// found in robots.txt Sitemap entries and at /sitemap.xml. Sitemap indexes
+// are expanded, and urls are filtered by the same domain and depth rules as
+// links, up to the url limit. It stops early when the crawl is stopped or ctx
+// is done.
+func (hc *HTTPChallenge) SitemapURLs(ctx context.Context, url string) []string {
+	queue := []string{}
+	if txt, err := hc.robots.Get(ctx, url); err == nil {
+		queue = append(queue, txt.Sitemaps...)
+	}
+	queue = append(queue, GetBaseURL(url)+"/sitemap.xml")
+
+	urls := []string{}
+	seenSitemaps := map[string]struct{}{}
+	for len(queue) > 0 && len(seenSitemaps) < sitemapMaxFiles && ctx.Err() == nil && !hc.Stopped() {
+		sitemap := queue[0]
+		queue = queue[1:]
+		if _, ok := seenSitemaps[sitemap]; ok {
+			continue
+		}
+		seenSitemaps[sitemap] = struct{}{}
+
+		pages, nested, err := hc.fetchSitemap(ctx, sitemap)
+		if err != nil {
+			continue
+		}
+		color.Secondary.Print("Sitemap")
+		color.Secondary.Print(".....................")
+		color.Secondary.Println(fmt.Sprintf("(%d) %s", len(pages)+len(nested), sitemap))
+
+		queue = append(queue, nested...)
+		for _, page := range pages {
+			href, ok := hc.acceptLink(page)
+			if !ok {
+				continue
+			}
+			urls = append(urls, href)
+		}
+		urls = UniqueStrings(urls)
+		if !hc.options.CrawlFromFile && len(urls) >= hc.options.LimitUrls {
+			return urls[:hc.options.LimitUrls]
+		}
+	}
+	return urls
+}
+
+// fetchSitemap gets the sitemap at url once its host is ready, paced like
+// the pages of the host, and returns its page and nested sitemap urls.
+func (hc *HTTPChallenge) fetchSitemap(ctx context.Context, url string) ([]string, []string, error) {
+	if err := hc.scheduler.Acquire(ctx, url); err != nil {
+		return nil, nil, err
+	}
+	defer hc.scheduler.Release(url)
+	resp, _, _, err := hc.getWithRetries(ctx, url, func(resp *response) int64 {
+		if resp.StatusCode != http.StatusOK {
+			return 0
+		}
+		return sitemapMaxBytes
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("sitemap %s: status %d", url, resp.StatusCode)
+	}
+	return ParseSitemap(resp.data)
+}
@@ -0,0 +1,129 @@
+package pkg
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func gzipBytes(t *testing.T, b []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestParseSitemap(t *testing.T) {
+	urlset := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>https://example.com/</loc></url>
+  <url><loc> https://example.com/about </loc></url>
+</urlset>`)
+	index := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>https://example.com/sitemap-1.xml.gz</loc></sitemap>
+</sitemapindex>`)
+
+	tests := []struct {
+		name         string
+		body         []byte
+		wantURLs     []string
+		wantSitemaps []string
+	}{
+		{"urlset", urlset, []string{"https://example.com/", "https://example.com/about"}, []string{}},
+		{"gzipped urlset", gzipBytes(t, urlset), []string{"https://example.com/", "https://example.com/about"}, []string{}},
+		{"index", index, []string{}, []string{"https://example.com/sitemap-1.xml.gz"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urls, sitemaps, err := ParseSitemap(tt.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !IsEqualSlice(urls, tt.wantURLs) {
+				t.Errorf("urls = %v, want %v", urls, tt.wantURLs)
+			}
+			if !IsEqualSlice(sitemaps, tt.wantSitemaps) {
+				t.Errorf("sitemaps = %v, want %v", sitemaps, tt.wantSitemaps)
+			}
+		})
+	}
+}
+
+func TestSitemapURLs(t *testing.T) {
+	mu := sync.Mutex{}
+	starts := []time.Time{}
+	mux := http.NewServeMux()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/robots.txt" {
+			mu.Lock()
+			starts = append(starts, time.Now())
+			mu.Unlock()
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "User-agent: *\nCrawl-delay: 0.2\nSitemap: %s/sitemap_index.xml\n", ts.URL)
+	})
+	mux.HandleFunc("/sitemap_index.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<sitemapindex><sitemap><loc>%s/pages.xml.gz</loc></sitemap></sitemapindex>`, ts.URL)
+	})
+	mux.HandleFunc("/pages.xml.gz", func(w http.ResponseWriter, r *http.Request) {
+		body := fmt.Sprintf(`<urlset>
+<url><loc>%[1]s/team</loc></url>
+<url><loc>%[1]s/team#jobs</loc></url>
+<url><loc>https://other.example.com/team</loc></url>
+</urlset>`, ts.URL)
+		_, _ = w.Write(gzipBytes(t, []byte(body)))
+	})
+	mux.HandleFunc("/sitemap.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<urlset><url><loc>%s/contact</loc></url></urlset>`, ts.URL)
+	})
+
+	hc, err := NewHTTPChallenge(func(opt *CrawlOptions) error {
+		opt.URL = ts.URL
+		opt.Depth = -1
+		opt.LimitUrls = 10
+		opt.TimeoutMillisecond = 1000
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	urls := hc.SitemapURLs(context.Background(), ts.URL)
+	sort.Strings(urls)
+	want := []string{ts.URL + "/contact", ts.URL + "/team"}
+	if !IsEqualSlice(urls, want) {
+		t.Errorf("SitemapURLs() = %v, want %v", urls, want)
+	}
+	// sitemaps are paced by the Crawl-delay like pages
+	if len(starts) != 3 {
+		t.Fatalf("requested %d sitemaps, want 3", len(starts))
+	}
+	for i := 1; i < len(starts); i++ {
+		if gap := starts[i].Sub(starts[i-1]); gap < 150*time.Millisecond {
+			t.Errorf("sitemap %d requested %v after the previous one, want the Crawl-delay of 200ms", i, gap)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if urls := hc.SitemapURLs(ctx, ts.URL); len(urls) != 0 || len(starts) != 3 {
+		t.Errorf("SitemapURLs(cancelled) = %v after %d requests, want none", urls, len(starts)-3)
+	}
+}
@@ -35,12 +35,10 @@ func UniqueStrings(input []string) []string {
 }
 
 func FilterOutCommonExtensions(input []string) []string {
-	exts := []string{".png", ".jpg", ".jpeg", ".gif", ".css", ".js", ".ico", ".svg", ".webp", ".pdf", ".zip", ".rar", ".tar", ".gz", ".7z", ".mp3", ".mp4", ".avi", ".mkv", ".mov", ".wmv", ".flv", ".m4v", ".webm", ".ogg", ".flac", ".wav", ".aac", ".wma", ".m4a", ".opus", ".mid", ".midi", ".mpg", ".mpeg", ".m4v", ".wmv", ".flv", ".m4v", ".webm", ".ogg", ".flac", ".wav", ".aac", ".wma", ".m4a", ".opus", ".mid", ".midi", ".mpg", ".mpeg"}
-
 	filtered := []string{}
 	for _, file := range input {
 		hasCommonExtension := false
-		for _, ext := range exts {
+		for _, ext := range commonFileExtensions {
 			if strings.HasSuffix(strings.ToLower(file), ext) {
 				hasCommonExtension = true
 				break
@@ -81,20 +79,3 @@ func WriteToFile(emails []string, path string) error {
 
 	return nil
 }
-
-func AppendEmailsToFile(emails []string, path string) error {
-	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	for _, email := range emails {
-		_, err := file.WriteString(email + "\n")
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
-}
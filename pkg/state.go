@@ -0,0 +1,190 @@
+package pkg
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const stateJournalFile = "journal.jsonl"
+
+const (
+	stateRecordStart  = "start"
+	stateRecordQueued = "queued"
+	stateRecordDone   = "done"
+	stateRecordEmails = "emails"
+)
+
+type stateRecord struct {
+	Type    string   `json:"t"`
+	URL     string   `json:"url,omitempty"`
+	URLFile string   `json:"file,omitempty"`
+	Emails  []string `json:"emails,omitempty"`
+	Crawled int      `json:"crawled,omitempty"`
+	Found   int      `json:"found,omitempty"`
+	Depth   int      `json:"depth,omitempty"`
+}
+
+// CrawlSnapshot is the crawl progress replayed from a state journal.
+type CrawlSnapshot struct {
+	URL              string
+	URLFile          string
+	Done             []string
+	Pending          []string
+	Depths           map[string]int
+	Emails           []string
+	TotalURLsCrawled int
+	TotalURLsFound   int
+}
+
+// CrawlState is an append-only journal of visited urls, the pending
+// frontier, discovered emails and counters, kept in a state directory so an
+// interrupted crawl can be resumed.
+type CrawlState struct {
+	mu       sync.Mutex
+	file     *os.File
+	snapshot *CrawlSnapshot
+}
+
+// OpenCrawlState opens the journal in dir, creating dir if needed, and
+// replays any previous crawl recorded there.
+func OpenCrawlState(dir string) (*CrawlState, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating state dir: %w", err)
+	}
+	path := filepath.Join(dir, stateJournalFile)
+
+	snapshot, size, err := replayJournal(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading state journal: %w", err)
+	}
+	// cut a line left short by a crash, for the next record not to be
+	// appended to it
+	if err := os.Truncate(path, size); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("error truncating state journal: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening state journal: %w", err)
+	}
+	return &CrawlState{file: file, snapshot: snapshot}, nil
+}
+
+// replayJournal replays the journal at path, and returns the size of its
+// complete lines, the ones ending with a newline.
+func replayJournal(path string) (*CrawlSnapshot, int64, error) {
+	snapshot := &CrawlSnapshot{Depths: map[string]int{}}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return snapshot, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	done := map[string]struct{}{}
+	queued := []string{}
+	size := int64(0)
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// a last line without a newline was cut short by a crash
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		size += int64(len(line))
+		var r stateRecord
+		if err := json.Unmarshal(line, &r); err != nil {
+			continue
+		}
+		switch r.Type {
+		case stateRecordStart:
+			snapshot.URL = r.URL
+			snapshot.URLFile = r.URLFile
+		case stateRecordQueued:
+			queued = append(queued, r.URL)
+			if _, ok := snapshot.Depths[r.URL]; !ok {
+				snapshot.Depths[r.URL] = r.Depth
+			}
+		case stateRecordDone:
+			if _, ok := done[r.URL]; !ok {
+				done[r.URL] = struct{}{}
+				snapshot.Done = append(snapshot.Done, r.URL)
+			}
+			snapshot.TotalURLsCrawled = max(snapshot.TotalURLsCrawled, r.Crawled)
+			snapshot.TotalURLsFound = max(snapshot.TotalURLsFound, r.Found)
+		case stateRecordEmails:
+			snapshot.Emails = append(snapshot.Emails, r.Emails...)
+		}
+	}
+
+	for _, u := range UniqueStrings(queued) {
+		if _, ok := done[u]; !ok {
+			snapshot.Pending = append(snapshot.Pending, u)
+		}
+	}
+	snapshot.Emails = UniqueStrings(snapshot.Emails)
+	return snapshot, size, nil
+}
+
+// Snapshot returns the progress replayed when the state was opened.
+func (s *CrawlState) Snapshot() *CrawlSnapshot {
+	return s.snapshot
+}
+
+// Resumable reports whether the state holds a previous crawl.
+func (s *CrawlState) Resumable() bool {
+	return s.snapshot.URL != "" || s.snapshot.URLFile != "" || len(s.snapshot.Done) > 0
+}
+
+func (s *CrawlState) Start(url, urlFile string) error {
+	return s.write(stateRecord{Type: stateRecordStart, URL: url, URLFile: urlFile})
+}
+
+func (s *CrawlState) Queued(url string, depth int) error {
+	return s.write(stateRecord{Type: stateRecordQueued, URL: url, Depth: depth})
+}
+
+func (s *CrawlState) Done(url string, crawled, found int) error {
+	return s.write(stateRecord{Type: stateRecordDone, URL: url, Crawled: crawled, Found: found})
+}
+
+func (s *CrawlState) AddEmails(url string, emails []string) error {
+	if len(emails) == 0 {
+		return nil
+	}
+	return s.write(stateRecord{Type: stateRecordEmails, URL: url, Emails: emails})
+}
+
+// write appends r as a single line, so a record is either fully in the
+// journal or cut short at the end of it.
+func (s *CrawlState) write(r stateRecord) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.file.Write(b)
+	return err
+}
+
+func (s *CrawlState) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.file.Sync(); err != nil {
+		return err
+	}
+	return s.file.Close()
+}
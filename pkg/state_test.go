@@ -0,0 +1,152 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestCrawlStateResume(t *testing.T) {
+	dir := t.TempDir()
+
+	state, err := OpenCrawlState(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Resumable() {
+		t.Fatal("new state should not be resumable")
+	}
+	for _, err := range []error{
+		state.Start("https://example.com", ""),
+		state.Queued("https://example.com/a", 0),
+		state.Queued("https://example.com/b", 1),
+		state.Queued("https://example.com/c", 1),
+		state.AddEmails("https://example.com/a", []string{"a@example.com"}),
+		state.Done("https://example.com/a", 2, 1),
+		state.Done("https://example.com/c", 3, 1),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := state.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// simulate a crash in the middle of a write
+	file, err := os.OpenFile(filepath.Join(dir, stateJournalFile), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteString(`{"t":"done","url":"https://exa`); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	state, err = OpenCrawlState(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot := state.Snapshot()
+	if !state.Resumable() {
+		t.Error("state should be resumable")
+	}
+	if snapshot.URL != "https://example.com" {
+		t.Errorf("URL = %q, want %q", snapshot.URL, "https://example.com")
+	}
+	if !IsEqualSlice(snapshot.Done, []string{"https://example.com/a", "https://example.com/c"}) {
+		t.Errorf("Done = %v", snapshot.Done)
+	}
+	if !IsEqualSlice(snapshot.Pending, []string{"https://example.com/b"}) {
+		t.Errorf("Pending = %v", snapshot.Pending)
+	}
+	if snapshot.Depths["https://example.com/b"] != 1 {
+		t.Errorf("Depths = %v", snapshot.Depths)
+	}
+	if !IsEqualSlice(snapshot.Emails, []string{"a@example.com"}) {
+		t.Errorf("Emails = %v", snapshot.Emails)
+	}
+	if snapshot.TotalURLsCrawled != 3 || snapshot.TotalURLsFound != 1 {
+		t.Errorf("counters = %d, %d, want 3, 1", snapshot.TotalURLsCrawled, snapshot.TotalURLsFound)
+	}
+
+	// the line cut short is dropped, not prefixed to the next record
+	if err := state.Done("https://example.com/b", 4, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := state.Close(); err != nil {
+		t.Fatal(err)
+	}
+	state, err = OpenCrawlState(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer state.Close()
+	if snapshot := state.Snapshot(); len(snapshot.Pending) != 0 || snapshot.TotalURLsCrawled != 4 {
+		t.Errorf("Pending = %v, TotalURLsCrawled = %d, want none and 4", snapshot.Pending, snapshot.TotalURLsCrawled)
+	}
+}
+
+func TestCrawlRecursiveResume(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	mu := sync.Mutex{}
+	requests := map[string]int{}
+	links := map[string]string{"/": `<a href="/a">a</a> <a href="/b">b</a>`, "/a": `<a href="/c">c</a>`, "/b": "", "/c": ""}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := links[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		mu.Lock()
+		requests[r.URL.Path]++
+		mu.Unlock()
+		// interrupted while crawling /a
+		if r.URL.Path == "/a" {
+			cancel()
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<html><body>page%s@acme.com %s</body></html>", r.URL.Path[1:], body)
+	}))
+	t.Cleanup(ts.Close)
+
+	dir := t.TempDir()
+	crawl := func(ctx context.Context) []string {
+		state, err := OpenCrawlState(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer state.Close()
+		hc := newTestHTTPChallenge(ts, "", false)
+		hc.options.URL = ts.URL
+		hc.UseState(state)
+		records := &recordSink{}
+		hc.AddSink(records)
+		urls := []string{ts.URL + "/"}
+		if state.Resumable() {
+			urls = hc.PendingURLs()
+		} else if err := state.Start(ts.URL, ""); err != nil {
+			t.Fatal(err)
+		}
+		hc.CrawlRecursiveURLs(ctx, urls)
+		emails := []string{}
+		for _, r := range records.Records() {
+			emails = append(emails, r.Value)
+		}
+		return emails
+	}
+	got := append(crawl(ctx), crawl(context.Background())...)
+	sort.Strings(got)
+	if want := []string{"page@acme.com", "pagea@acme.com", "pageb@acme.com", "pagec@acme.com"}; !IsEqualSlice(got, want) {
+		t.Errorf("emails = %v, want %v", got, want)
+	}
+	if requests["/"] != 1 {
+		t.Errorf("requests = %v, want / crawled once", requests)
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"bufio"
 	"net/url"
 	"os"
-	"regexp"
 	"strings"
 )
 
@@ -79,26 +78,37 @@ func GetBaseURL(u string) string {
 	return parsedURL.Scheme + "://" + parsedURL.Host
 }
 
+// ExtractEmailsFromText returns the valid emails in text, plain or hidden in
+// any way Deobfuscators know, normalized.
 func ExtractEmailsFromText(text string) []string {
-	// Regular expression to match email addresses
-	re := regexp.MustCompile(`[a-zA-Z0-9._%+-]+([@]|[(\[{<]at[)\]}>])[a-zA-Z0-9.-]+([.]|[(\[{<]dot[)\]}>])\w[a-zA-Z]{2,}`)
+	emails := []string{}
+	for _, m := range ExtractEmailMatchesFromText(text, Deobfuscators) {
+		if email, err := NormalizeEmail(m.Email); err == nil {
+			emails = append(emails, email)
+		}
+	}
+	return UniqueStrings(emails)
+}
 
-	// Find all email addresses in the text
-	emails := re.FindAllString(text, -1)
+// EmailMatch is an email found in a page and the method it was found with.
+type EmailMatch struct {
+	Email  string
+	Method string
+}
 
-	// Replace the obfuscated "at" and "dot" with "@" and "."
-	replacementFunc := func(match string) string {
-		match = regexp.MustCompile(`[(\[{<]at[)\]}>]`).ReplaceAllString(match, "@")
-		match = regexp.MustCompile(`[(\[{<]dot[)\]}>]`).ReplaceAllString(match, ".")
-		return match
+// ExtractEmailMatchesFromText returns the candidate emails in text, plain
+// ones first, then the ones decoded by deobfuscators.
+func ExtractEmailMatchesFromText(text string, deobfuscators []Deobfuscator) []EmailMatch {
+	matches := []EmailMatch{}
+	for _, email := range plainEmailRegexp.FindAllString(escapeRegexp.ReplaceAllString(text, " "), -1) {
+		matches = append(matches, EmailMatch{Email: email, Method: MethodText})
 	}
-
-	// Apply the replacement function to each found email
-	for i, email := range emails {
-		emails[i] = replacementFunc(email)
+	for _, d := range deobfuscators {
+		for _, email := range d.Decode(text) {
+			matches = append(matches, EmailMatch{Email: email, Method: MethodDeobfuscated})
+		}
 	}
-
-	return emails
+	return matches
 }
 
 func RelativeToAbsoluteURL(href, currentURL, baseURL string) string {
@@ -133,13 +143,15 @@ func RelativeToAbsoluteURL(href, currentURL, baseURL string) string {
 	return parsedCurrentURL.ResolveReference(u).String()
 }
 
+// CountPerDomain counts emails per registrable domain, so
+// mail.example.co.uk and example.co.uk count together.
 func CountPerDomain(emails []string) map[string]int {
 	domainCounts := make(map[string]int)
 
 	for _, email := range emails {
-		parts := strings.Split(email, "@")
-		if len(parts) == 2 {
-			domain := parts[1]
+		at := strings.LastIndex(email, "@")
+		if at > 0 {
+			domain := RegistrableDomain(email[at+1:])
 			domainCounts[domain]++
 		}
 	}
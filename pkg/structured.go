@@ -0,0 +1,308 @@
+package pkg
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// contactItem is a person, organization or contact point of the structured
+// data of a page, or of a vcard, calendar or feed.
+type contactItem struct {
+	method string
+	// node is the element of microdata and h-cards, nil for json-ld
+	node         *html.Node
+	emails       []string
+	phones       []string
+	name         string
+	jobTitle     string
+	organization string
+}
+
+type structuredExtractor struct {
+	phones bool
+}
+
+func newStructuredExtractor(opt *CrawlOptions) (Extractor, error) {
+	return structuredExtractor{phones: StringInSlice("phones", opt.Extract)}, nil
+}
+
+// Extract finds the emails, and the phones when extracting phones, of the
+// schema.org json-ld and microdata and the h-cards of the page, or of the
+// contacts of other content, with the names, job titles and organizations
+// they are published with.
+func (e structuredExtractor) Extract(page *Page) []Finding {
+	findings := []Finding{}
+	items := append([]contactItem{}, page.contacts...)
+	if page.DOM != nil {
+		items = append(items, jsonLDItems(page.DOM)...)
+		items = append(items, microdataItems(page.DOM)...)
+		items = append(items, hCardItems(page.DOM)...)
+	}
+
+	for _, item := range items {
+		c := Context{Name: item.name, JobTitle: item.jobTitle, Organization: item.organization}
+		if item.node != nil {
+			c.Heading = nearestHeading(item.node)
+		}
+		for _, value := range item.emails {
+			candidates := []string{value}
+			if strings.HasPrefix(strings.ToLower(value), "mailto:") {
+				candidates = ParseMailto(value)
+			}
+			for _, candidate := range candidates {
+				email, err := NormalizeEmail(candidate)
+				if err != nil {
+					Logger().WithField("url", page.URL).Debug(err)
+					continue
+				}
+				findings = append(findings, Finding{Type: RecordEmail, Value: email, Method: item.method, Context: c})
+			}
+		}
+		if !e.phones {
+			continue
+		}
+		for _, value := range item.phones {
+			if len(value) > 4 && strings.EqualFold(value[:4], "tel:") {
+				value = value[4:]
+			}
+			if number := NormalizePhone(value); number != "" {
+				findings = append(findings, Finding{Type: RecordPhone, Value: number, Method: item.method, Context: c})
+			}
+		}
+	}
+	return findings
+}
+
+// jsonLDItems returns the things with an email or telephone of the json-ld
+// scripts of dom.
+func jsonLDItems(dom *goquery.Selection) []contactItem {
+	items := []contactItem{}
+	dom.Find(`script[type="application/ld+json"]`).Each(func(_ int, s *goquery.Selection) {
+		var v any
+		if err := json.Unmarshal([]byte(s.Text()), &v); err == nil {
+			items = append(items, jsonLDValueItems(v)...)
+		}
+	})
+	return items
+}
+
+// jsonLDValueItems returns the things with an email or telephone of the
+// decoded json-ld v. People take the organization they work for, or are
+// nested in, and contact points the one they are nested in.
+func jsonLDValueItems(v any) []contactItem {
+	items := []contactItem{}
+	var walk func(v any, org string)
+	walk = func(v any, org string) {
+		switch v := v.(type) {
+		case []any:
+			for _, child := range v {
+				walk(child, org)
+			}
+		case map[string]any:
+			types := []string{}
+			for _, t := range ldStrings(v["@type"]) {
+				types = append(types, schemaType(t))
+			}
+			name := ldName(v["name"])
+			item := contactItem{
+				method:       MethodJSONLD,
+				emails:       ldStrings(v["email"]),
+				phones:       ldStrings(v["telephone"]),
+				jobTitle:     ldName(v["jobTitle"]),
+				organization: org,
+			}
+			switch {
+			case StringInSlice("Person", types):
+				item.name = name
+				if worksFor := ldName(v["worksFor"]); worksFor != "" {
+					item.organization = worksFor
+				}
+			case StringInSlice("ContactPoint", types), StringInSlice("PostalAddress", types):
+			case name != "":
+				org = name
+				item.name, item.organization = name, name
+			}
+			if len(item.emails) > 0 || len(item.phones) > 0 {
+				items = append(items, item)
+			}
+			keys := []string{}
+			for key := range v {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				walk(v[key], org)
+			}
+		}
+	}
+	walk(v, "")
+	return items
+}
+
+// ldStrings returns the strings of a json-ld value, a string or an array.
+func ldStrings(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{strings.TrimSpace(v)}
+	case []any:
+		values := []string{}
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, strings.TrimSpace(s))
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// ldName returns a json-ld value as a string, the name of an object, or the
+// first of an array.
+func ldName(v any) string {
+	switch v := v.(type) {
+	case string:
+		return collapseSpace(v)
+	case map[string]any:
+		return ldName(v["name"])
+	case []any:
+		if len(v) > 0 {
+			return ldName(v[0])
+		}
+	}
+	return ""
+}
+
+// schemaType returns the type of https://schema.org/Person or schema:Person
+// as Person.
+func schemaType(t string) string {
+	return t[strings.LastIndexAny(t, "/:")+1:]
+}
+
+// microdataItems returns the items with an email or telephone of the
+// microdata of dom.
+func microdataItems(dom *goquery.Selection) []contactItem {
+	items := []contactItem{}
+	dom.Find("[itemscope][itemtype]").Each(func(_ int, scope *goquery.Selection) {
+		props := microdataProps(scope)
+		item := contactItem{
+			method:   MethodMicrodata,
+			node:     scope.Get(0),
+			emails:   props["email"],
+			phones:   props["telephone"],
+			jobTitle: first(props["jobTitle"]),
+		}
+		if len(item.emails) == 0 && len(item.phones) == 0 {
+			return
+		}
+		name := first(props["name"])
+		switch schemaType(scope.AttrOr("itemtype", "")) {
+		case "Person":
+			item.name = name
+			item.organization = first(props["worksFor"])
+			if item.organization == "" {
+				item.organization = microdataOrganization(scope)
+			}
+		case "ContactPoint", "PostalAddress":
+			item.organization = microdataOrganization(scope)
+		default:
+			item.name, item.organization = name, name
+		}
+		items = append(items, item)
+	})
+	return items
+}
+
+// microdataProps returns the values of the properties of the item scope,
+// leaving out those of the items nested in it, which are themselves values
+// of the name they have.
+func microdataProps(scope *goquery.Selection) map[string][]string {
+	props := map[string][]string{}
+	scope.Find("[itemprop]").Each(func(_ int, s *goquery.Selection) {
+		if !s.Parent().Closest("[itemscope]").IsSelection(scope) {
+			return
+		}
+		var value string
+		switch {
+		case s.Is("[itemscope]"):
+			value = first(microdataProps(s)["name"])
+		case s.Is("[content]"):
+			value = s.AttrOr("content", "")
+		case s.Is("a[href], link[href], area[href]"):
+			value = s.AttrOr("href", "")
+		default:
+			value = collapseSpace(s.Text())
+		}
+		if value = strings.TrimSpace(value); value == "" {
+			return
+		}
+		for _, name := range strings.Fields(s.AttrOr("itemprop", "")) {
+			props[name] = append(props[name], value)
+		}
+	})
+	return props
+}
+
+// microdataOrganization returns the name of the item scope is nested in,
+// unless a person or contact point.
+func microdataOrganization(scope *goquery.Selection) string {
+	parent := scope.Parent().Closest("[itemscope][itemtype]")
+	if parent.Length() == 0 {
+		return ""
+	}
+	switch schemaType(parent.AttrOr("itemtype", "")) {
+	case "Person", "ContactPoint", "PostalAddress":
+		return microdataOrganization(parent)
+	}
+	return first(microdataProps(parent)["name"])
+}
+
+const hCardSelector = ".h-card, .vcard"
+
+// hCardItems returns the h-cards, and the classic vcards, of dom with an
+// email or telephone.
+func hCardItems(dom *goquery.Selection) []contactItem {
+	items := []contactItem{}
+	dom.Find(hCardSelector).Each(func(_ int, card *goquery.Selection) {
+		// the properties of card, not of the cards nested in it
+		props := func(selector string) *goquery.Selection {
+			return card.Find(selector).FilterFunction(func(_ int, s *goquery.Selection) bool {
+				return s.Parent().Closest(hCardSelector).IsSelection(card)
+			})
+		}
+		item := contactItem{
+			method:   MethodHCard,
+			node:     card.Get(0),
+			name:     collapseSpace(props(".p-name, .fn").First().Text()),
+			jobTitle: collapseSpace(props(".p-job-title, .title").First().Text()),
+		}
+		props(".u-email, .email").Each(func(_ int, s *goquery.Selection) {
+			item.emails = append(item.emails, s.AttrOr("href", collapseSpace(s.Text())))
+		})
+		props(".p-tel, .u-tel, .tel").Each(func(_ int, s *goquery.Selection) {
+			item.phones = append(item.phones, s.AttrOr("href", collapseSpace(s.Text())))
+		})
+		if len(item.emails) == 0 && len(item.phones) == 0 {
+			return
+		}
+		org := props(".p-org, .org").First()
+		if org.Is(hCardSelector) {
+			item.organization = collapseSpace(org.Find(".p-name, .fn").First().Text())
+		}
+		if item.organization == "" {
+			item.organization = collapseSpace(org.Text())
+		}
+		items = append(items, item)
+	})
+	return items
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
@@ -0,0 +1,105 @@
+package pkg
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const testStructuredPage = `<html><head>
+<script type="application/ld+json">{"@context": "https://schema.org", "@graph": [
+  {"@type": "Organization", "name": "Acme Corp", "email": "info@acme.com",
+   "contactPoint": {"@type": "ContactPoint", "contactType": "sales", "email": "sales@acme.com", "telephone": "+1-555-010-0199"}},
+  {"@type": "Person", "name": "Ada Lovelace", "jobTitle": "Chief Analyst", "email": ["mailto:ada@acme.com"], "worksFor": {"@type": "Organization", "name": "Acme Corp"}}]}</script>
+<script type="application/ld+json">{"@type": "Person", "name": "Broken", "email": </script>
+</head><body>
+<h2>Press</h2>
+<div itemscope itemtype="https://schema.org/Organization"><span itemprop="name">Acme Labs</span>
+  <div itemprop="employee" itemscope itemtype="https://schema.org/Person">
+    <span itemprop="name">Linus Pauling</span>, <span itemprop="jobTitle">Chemist</span>,
+    <a itemprop="email" href="mailto:linus@acme.com">write</a>
+  </div>
+</div>
+<h2>Team</h2>
+<div class="h-card"><a class="p-name u-email" href="mailto:grace@acme.com">Grace Hopper</a>
+  <span class="p-job-title">Rear Admiral</span>
+  <div class="p-org h-card"><span class="p-name">Navy</span> <a class="u-email" href="mailto:navy@acme.com">mail</a></div>
+</div>
+<div class="vcard"><span class="fn">Alan Turing</span> <span class="title">Mathematician</span> <span class="org">Bletchley</span>
+  <span class="email">alan@acme.com</span> <span class="tel">+44 20 7946 0018</span></div>
+<p>Or write to ada@acme.com.</p>
+</body></html>`
+
+func TestExtractStructured(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(testStructuredPage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{"structured", "phones"}
+	extractors, err := NewExtractors(names, &CrawlOptions{Extract: names})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// phones, then structured, in the order they are registered
+	got := extractors[1].Extract(&Page{URL: "https://acme.com/team", Body: testStructuredPage, DOM: doc.Selection})
+
+	want := []Finding{
+		{Type: RecordEmail, Value: "info@acme.com", Method: MethodJSONLD, Context: Context{Name: "Acme Corp", Organization: "Acme Corp"}},
+		{Type: RecordEmail, Value: "sales@acme.com", Method: MethodJSONLD, Context: Context{Organization: "Acme Corp"}},
+		{Type: RecordPhone, Value: "+15550100199", Method: MethodJSONLD, Context: Context{Organization: "Acme Corp"}},
+		{Type: RecordEmail, Value: "ada@acme.com", Method: MethodJSONLD, Context: Context{Name: "Ada Lovelace", JobTitle: "Chief Analyst", Organization: "Acme Corp"}},
+		{Type: RecordEmail, Value: "linus@acme.com", Method: MethodMicrodata, Context: Context{Heading: "Press", Name: "Linus Pauling", JobTitle: "Chemist", Organization: "Acme Labs"}},
+		{Type: RecordEmail, Value: "grace@acme.com", Method: MethodHCard, Context: Context{Heading: "Team", Name: "Grace Hopper", JobTitle: "Rear Admiral", Organization: "Navy"}},
+		{Type: RecordEmail, Value: "navy@acme.com", Method: MethodHCard, Context: Context{Heading: "Team", Name: "Navy"}},
+		{Type: RecordEmail, Value: "alan@acme.com", Method: MethodHCard, Context: Context{Heading: "Team", Name: "Alan Turing", JobTitle: "Mathematician", Organization: "Bletchley"}},
+		{Type: RecordPhone, Value: "+442079460018", Method: MethodHCard, Context: Context{Heading: "Team", Name: "Alan Turing", JobTitle: "Mathematician", Organization: "Bletchley"}},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Extract() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("finding %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestCrawlStructuredConfidence(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(testStructuredPage))
+	}))
+	t.Cleanup(ts.Close)
+
+	hc := newTestHTTPChallenge(ts, "", false)
+	hc.options.SnippetWindow = 40
+	extractors, err := NewExtractors(hc.options.Extract, hc.options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hc.extractors = extractors
+	records := &recordSink{}
+	hc.AddSink(records)
+	hc.CrawlRecursiveParallel(context.Background(), ts.URL)
+
+	got := map[string]Record{}
+	for _, r := range records.Records() {
+		got[r.Value] = r
+	}
+	// found as text and in json-ld, the json-ld finding is kept with the
+	// snippet of the text one
+	ada := got["ada@acme.com"]
+	if ada.Method != MethodJSONLD || ada.Confidence != Confidence(MethodJSONLD) {
+		t.Errorf("ada@acme.com method %q confidence %v, want %q %v", ada.Method, ada.Confidence, MethodJSONLD, Confidence(MethodJSONLD))
+	}
+	if ada.JobTitle != "Chief Analyst" || ada.Snippet != "Or write to ada@acme.com." {
+		t.Errorf("ada@acme.com = %+v, want the job title and snippet", ada)
+	}
+	if _, ok := got["+15550100199"]; ok {
+		t.Error("phone recorded without extracting phones")
+	}
+}
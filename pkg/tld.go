@@ -0,0 +1,50 @@
+package pkg
+
+import (
+	"bufio"
+	_ "embed"
+	"strings"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
+)
+
+//go:generate curl -sSfo tlds-alpha-by-domain.txt https://data.iana.org/TLD/tlds-alpha-by-domain.txt
+
+//go:embed tlds-alpha-by-domain.txt
+var tldList string
+
+// tlds are the top level domains of the IANA root zone, lowercase and in
+// punycode.
+var tlds = parseTLDs(tldList)
+
+func parseTLDs(list string) map[string]bool {
+	result := map[string]bool{}
+	scanner := bufio.NewScanner(strings.NewReader(list))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		result[strings.ToLower(line)] = true
+	}
+	return result
+}
+
+// RegistrableDomain returns the domain one label below the public suffix of
+// domain, like example.co.uk for mail.example.co.uk, or domain itself when it
+// has none.
+func RegistrableDomain(domain string) string {
+	ascii, err := idna.Lookup.ToASCII(domain)
+	if err != nil {
+		return domain
+	}
+	registrable, err := publicsuffix.EffectiveTLDPlusOne(ascii)
+	if err != nil {
+		return domain
+	}
+	if unicodeDomain, err := idna.Lookup.ToUnicode(registrable); err == nil {
+		return unicodeDomain
+	}
+	return registrable
+}
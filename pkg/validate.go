@@ -0,0 +1,174 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
+)
+
+// InvalidEmailError is the reason a candidate is not an email address.
+type InvalidEmailError struct {
+	Candidate string
+	Reason    string
+}
+
+func (e *InvalidEmailError) Error() string {
+	return fmt.Sprintf("rejected %q: %s", e.Candidate, e.Reason)
+}
+
+const (
+	// atext of RFC 5322, the characters of a local part besides letters and
+	// digits
+	localPartSpecials = "!#$%&'*+-/=?^_`{|}~"
+	// the punctuation a sentence or markup may glue to an address
+	leadingPunctuation  = `<(["'`
+	trailingPunctuation = `.,;:!?)]}>"'`
+)
+
+// commonFileExtensions end asset names that look like addresses, like
+// logo@2x.png.
+var commonFileExtensions = []string{".png", ".jpg", ".jpeg", ".gif", ".css", ".js", ".ico", ".svg", ".webp", ".pdf", ".zip", ".rar", ".tar", ".gz", ".7z", ".mp3", ".mp4", ".avi", ".mkv", ".mov", ".wmv", ".flv", ".m4v", ".webm", ".ogg", ".flac", ".wav", ".aac", ".wma", ".m4a", ".opus", ".mid", ".midi", ".mpg", ".mpeg"}
+
+// NormalizeEmail validates candidate as an address of RFC 5322, or of
+// RFC 6531 with a utf-8 local part or an internationalized domain. It returns
+// the address without surrounding punctuation, with the domain lowercased
+// and in unicode, so the punycode and unicode forms are the same address.
+func NormalizeEmail(candidate string) (string, error) {
+	reject := func(format string, args ...any) (string, error) {
+		return "", &InvalidEmailError{Candidate: candidate, Reason: fmt.Sprintf(format, args...)}
+	}
+
+	email := strings.TrimSpace(candidate)
+	if !strings.HasPrefix(email, `"`) || !strings.Contains(email, `"@`) {
+		email = strings.TrimLeft(email, leadingPunctuation)
+	}
+	email = strings.TrimRight(email, trailingPunctuation)
+	if !utf8.ValidString(email) {
+		return reject("not utf-8")
+	}
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return reject("no @")
+	}
+	local, domain := email[:at], email[at+1:]
+
+	if reason := localPartError(local); reason != "" {
+		return reject("%s", reason)
+	}
+	domain, reason := normalizeDomain(domain)
+	if reason != "" {
+		return reject("%s", reason)
+	}
+	return local + "@" + domain, nil
+}
+
+func localPartError(local string) string {
+	switch {
+	case local == "":
+		return "empty local part"
+	case len(local) > 64:
+		return "local part longer than 64 bytes"
+	case len(local) > 1 && local[0] == '"' && local[len(local)-1] == '"':
+		return quotedLocalPartError(local[1 : len(local)-1])
+	case local[0] == '.':
+		return "local part starts with a dot"
+	case local[len(local)-1] == '.':
+		return "local part ends with a dot"
+	case strings.Contains(local, ".."):
+		return "local part has consecutive dots"
+	}
+	for _, c := range local {
+		if c == '.' || strings.ContainsRune(localPartSpecials, c) || isAlphanumeric(c) {
+			continue
+		}
+		return fmt.Sprintf("local part has %q", c)
+	}
+	return ""
+}
+
+// quotedLocalPartError checks the inside of a quoted string local part,
+// like "john doe"@example.com.
+func quotedLocalPartError(quoted string) string {
+	escaped := false
+	for _, c := range quoted {
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == '"':
+			return "quoted local part has an unescaped quote"
+		case c < ' ' || c == 0x7f:
+			return "quoted local part has a control character"
+		}
+	}
+	if escaped {
+		return "quoted local part ends with a backslash"
+	}
+	return ""
+}
+
+// normalizeDomain returns the lowercased unicode form of domain, or the
+// reason it is not a domain of an address.
+func normalizeDomain(domain string) (string, string) {
+	if domain == "" {
+		return "", "empty domain"
+	}
+	if strings.HasPrefix(domain, "[") {
+		return "", "domain is an address literal"
+	}
+	ascii, err := idna.Lookup.ToASCII(domain)
+	if err != nil {
+		return "", fmt.Sprintf("invalid domain: %v", err)
+	}
+	if len(ascii) > 253 {
+		return "", "domain longer than 253 bytes"
+	}
+	labels := strings.Split(ascii, ".")
+	if len(labels) < 2 {
+		return "", "domain has no top level domain"
+	}
+	for _, label := range labels {
+		switch {
+		case label == "":
+			return "", "domain has an empty label"
+		case len(label) > 63:
+			return "", fmt.Sprintf("domain label %q longer than 63 bytes", label)
+		}
+	}
+	tld := labels[len(labels)-1]
+	if !strings.HasPrefix(tld, "xn--") && (len(tld) < 2 || strings.IndexFunc(tld, func(c rune) bool { return c < 'a' || c > 'z' }) >= 0) {
+		return "", fmt.Sprintf("top level domain %q is not letters", tld)
+	}
+	if StringInSlice("."+tld, commonFileExtensions) {
+		return "", fmt.Sprintf("looks like a file name ending in .%s", tld)
+	}
+	if !tlds[tld] {
+		return "", fmt.Sprintf("unknown top level domain %q", tld)
+	}
+	if suffix, icann := publicsuffix.PublicSuffix(ascii); icann && suffix == ascii {
+		return "", fmt.Sprintf("domain %q is a public suffix", ascii)
+	}
+
+	unicodeDomain, err := idna.Lookup.ToUnicode(ascii)
+	if err != nil {
+		return "", fmt.Sprintf("invalid punycode: %v", err)
+	}
+	if roundTrip, err := idna.Lookup.ToASCII(unicodeDomain); err != nil || roundTrip != ascii {
+		return "", fmt.Sprintf("punycode of %q does not round-trip", unicodeDomain)
+	}
+	return unicodeDomain, ""
+}
+
+// isAlphanumeric reports whether c is an ascii letter or digit, or a utf-8
+// letter, mark or digit of RFC 6531.
+func isAlphanumeric(c rune) bool {
+	if c < utf8.RuneSelf {
+		return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+	}
+	return unicode.IsLetter(c) || unicode.IsMark(c) || unicode.IsDigit(c)
+}
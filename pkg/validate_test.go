@@ -0,0 +1,96 @@
+package pkg
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		candidate string
+		expected  string
+	}{
+		{"john@example.com", "john@example.com"},
+		{"John.Doe@EXAMPLE.Com", "John.Doe@example.com"},
+		{"john+news@mail.example.co.uk", "john+news@mail.example.co.uk"},
+		{"o'brien@example.com", "o'brien@example.com"},
+		{`"john doe"@example.com`, `"john doe"@example.com`},
+		{"(john@example.com).", "john@example.com"},
+		{"<john@example.com>,", "john@example.com"},
+		{"info@bücher.de", "info@bücher.de"},
+		{"info@xn--bcher-kva.de", "info@bücher.de"},
+		{"info@BÜCHER.de", "info@bücher.de"},
+		{"用户@例子.中国", "用户@例子.中国"},
+		{"john@example.github.io", "john@example.github.io"},
+		{"josé@example.com", "josé@example.com"},
+	}
+	for _, test := range tests {
+		t.Run(test.candidate, func(t *testing.T) {
+			got, err := NormalizeEmail(test.candidate)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.expected {
+				t.Errorf("NormalizeEmail(%q) = %q, want %q", test.candidate, got, test.expected)
+			}
+		})
+	}
+}
+
+func TestNormalizeEmailRejects(t *testing.T) {
+	tests := []struct {
+		candidate string
+		reason    string
+	}{
+		{"name@domain.c0m", `top level domain "c0m" is not letters`},
+		{".john@example.com", "local part starts with a dot"},
+		{"john.@example.com", "local part ends with a dot"},
+		{"john..doe@example.com", "local part has consecutive dots"},
+		{"jo hn@example.com", `local part has ' '`},
+		{`"jo"hn"@example.com`, "quoted local part has an unescaped quote"},
+		{"@example.com", "empty local part"},
+		{"john@localhost", "domain has no top level domain"},
+		{"john@-example.com", `invalid domain: idna: invalid label "-example"`},
+		{"john@example..com", "domain has an empty label"},
+		{"john@[127.0.0.1]", "domain is an address literal"},
+		{"logo@2x.png", "looks like a file name ending in .png"},
+		{"user@version.min", `unknown top level domain "min"`},
+		{"asset@image.webpack", `unknown top level domain "webpack"`},
+		{"john@co.uk", `domain "co.uk" is a public suffix`},
+		{"john.example.com", "no @"},
+	}
+	for _, test := range tests {
+		t.Run(test.candidate, func(t *testing.T) {
+			got, err := NormalizeEmail(test.candidate)
+			var invalid *InvalidEmailError
+			if !errors.As(err, &invalid) {
+				t.Fatalf("NormalizeEmail(%q) = %q, %v, want an InvalidEmailError", test.candidate, got, err)
+			}
+			if invalid.Reason != test.reason {
+				t.Errorf("NormalizeEmail(%q) reason = %q, want %q", test.candidate, invalid.Reason, test.reason)
+			}
+		})
+	}
+}
+
+func TestExtractEmailsFromText(t *testing.T) {
+	text := `{"contact":"<john@example.com>"} sales@Example.COM, logo@2x.png and name@domain.c0m.`
+	want := []string{"john@example.com", "sales@example.com"}
+	if got := ExtractEmailsFromText(text); !IsEqualSlice(got, want) {
+		t.Errorf("ExtractEmailsFromText() = %v, want %v", got, want)
+	}
+}
+
+func TestCountPerDomain(t *testing.T) {
+	emails := []string{"a@example.co.uk", "b@mail.example.co.uk", "c@example.com", "d@bücher.de", "e@shop.xn--bcher-kva.de", "f@me.github.io"}
+	want := map[string]int{"example.co.uk": 2, "example.com": 1, "bücher.de": 2, "me.github.io": 1}
+	got := CountPerDomain(emails)
+	if len(got) != len(want) {
+		t.Fatalf("CountPerDomain() = %v, want %v", got, want)
+	}
+	for domain, count := range want {
+		if got[domain] != count {
+			t.Errorf("CountPerDomain()[%q] = %d, want %d", domain, got[domain], count)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package pkg
+
+import (
+	"strings"
+)
+
+// contentLine is a property of a vcard or an icalendar, like
+// EMAIL;TYPE=work:jane@example.com, its name and parameter names in upper
+// case, without the group of item1.EMAIL.
+type contentLine struct {
+	name   string
+	params map[string][]string
+	value  string
+}
+
+// unfold joins the lines of a vcard or an icalendar folded on the next one
+// starting with a space or a tab.
+func unfold(data []byte) string {
+	s := strings.ReplaceAll(string(data), "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\n ", "")
+	return strings.ReplaceAll(s, "\n\t", "")
+}
+
+// contentLines returns the properties of the unfolded text s, leaving out
+// malformed lines.
+func contentLines(s string) []contentLine {
+	lines := []contentLine{}
+	for _, line := range strings.Split(s, "\n") {
+		// the colon ending the name and parameters, not one of a quoted value
+		quoted, colon := false, -1
+		for i, r := range line {
+			if r == '"' {
+				quoted = !quoted
+			}
+			if r == ':' && !quoted {
+				colon = i
+				break
+			}
+		}
+		if colon <= 0 {
+			continue
+		}
+		parts := splitUnquoted(line[:colon], ';')
+		name := strings.ToUpper(strings.TrimSpace(parts[0]))
+		name = name[strings.LastIndex(name, ".")+1:]
+		cl := contentLine{name: name, params: map[string][]string{}, value: strings.TrimSpace(line[colon+1:])}
+		for _, param := range parts[1:] {
+			key, value, ok := strings.Cut(param, "=")
+			if !ok {
+				// like EMAIL;INTERNET: of vcard 2.1
+				key, value = "TYPE", param
+			}
+			key = strings.ToUpper(strings.TrimSpace(key))
+			for _, v := range splitUnquoted(value, ',') {
+				cl.params[key] = append(cl.params[key], strings.Trim(v, `"`))
+			}
+		}
+		lines = append(lines, cl)
+	}
+	return lines
+}
+
+// splitUnquoted splits s at each sep not in double quotes.
+func splitUnquoted(s string, sep rune) []string {
+	parts := []string{}
+	quoted, start := false, 0
+	for i, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// vcardComponents returns the components of a structured value, like the
+// family and given names of N, split at the semicolons not escaped, and
+// unescaped.
+func vcardComponents(value string) []string {
+	components := []string{}
+	b := strings.Builder{}
+	escaped := false
+	for _, r := range value {
+		switch {
+		case escaped:
+			if r == 'n' || r == 'N' {
+				r = '\n'
+			}
+			b.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ';':
+			components = append(components, strings.TrimSpace(b.String()))
+			b.Reset()
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return append(components, strings.TrimSpace(b.String()))
+}
+
+// vcardText returns an unstructured value unescaped.
+func vcardText(value string) string {
+	return collapseSpace(strings.Join(vcardComponents(value), ";"))
+}
+
+// ReadVCard returns the text of vcards, with their people and organizations
+// as contacts: the formatted name, or the given and family names, the
+// emails, telephones, title and organization of each.
+func ReadVCard(data []byte) (*Document, error) {
+	s := unfold(data)
+	doc := &Document{Text: s}
+	var card *contactItem
+	for _, line := range contentLines(s) {
+		switch line.name {
+		case "BEGIN":
+			if strings.EqualFold(line.value, "VCARD") {
+				card = &contactItem{method: MethodVCard}
+			}
+			continue
+		case "END":
+			if card != nil && (len(card.emails) > 0 || len(card.phones) > 0) {
+				doc.contacts = append(doc.contacts, *card)
+			}
+			card = nil
+			continue
+		}
+		if card == nil {
+			continue
+		}
+		switch line.name {
+		case "FN":
+			card.name = vcardText(line.value)
+		case "N":
+			if card.name == "" {
+				n := append(vcardComponents(line.value), "", "")
+				card.name = collapseSpace(n[1] + " " + n[0])
+			}
+		case "EMAIL":
+			card.emails = append(card.emails, vcardText(line.value))
+		case "TEL":
+			card.phones = append(card.phones, vcardText(line.value))
+		case "TITLE":
+			card.jobTitle = vcardText(line.value)
+		case "ORG":
+			card.organization = vcardComponents(line.value)[0]
+		}
+	}
+	return doc, nil
+}
+
+// ReadICal returns the text of an icalendar, titled by its name or its first
+// summary, with the organizers and attendees of its events as contacts, by
+// their common names.
+func ReadICal(data []byte) (*Document, error) {
+	s := unfold(data)
+	doc := &Document{Text: s}
+	for _, line := range contentLines(s) {
+		switch line.name {
+		case "X-WR-CALNAME":
+			doc.Title = vcardText(line.value)
+		case "SUMMARY":
+			if doc.Title == "" {
+				doc.Title = vcardText(line.value)
+			}
+		case "ORGANIZER", "ATTENDEE":
+			doc.contacts = append(doc.contacts, contactItem{
+				method: MethodICal,
+				emails: []string{line.value},
+				name:   collapseSpace(first(line.params["CN"])),
+			})
+		}
+	}
+	return doc, nil
+}
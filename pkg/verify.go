@@ -0,0 +1,255 @@
+package pkg
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+	"golang.org/x/net/idna"
+)
+
+// MX statuses of the domain of an email.
+const (
+	MXOK       = "mx_ok"
+	MXNone     = "no_mx"
+	MXNXDomain = "nxdomain"
+)
+
+// MXStatuses are the MX statuses, in the order of the summary.
+var MXStatuses = []string{MXOK, MXNone, MXNXDomain}
+
+// MXVerifier finds whether the domains of emails accept mail, from their MX
+// records or, without any, their A and AAAA records. Each domain is looked up
+// once, and at most concurrency lookups run at the same time.
+type MXVerifier struct {
+	resolver string
+	timeout  time.Duration
+	sem      chan struct{}
+
+	mu    sync.Mutex
+	cache map[string]*mxLookup
+}
+
+type mxLookup struct {
+	done   chan struct{}
+	status string
+	err    error
+}
+
+// NewMXVerifier returns a verifier querying the DNS server resolver, as
+// host:port or host, or the first nameserver of /etc/resolv.conf when empty.
+func NewMXVerifier(resolver string, concurrency int, timeout time.Duration) *MXVerifier {
+	if resolver == "" {
+		resolver = SystemResolver()
+	}
+	if _, _, err := net.SplitHostPort(resolver); err != nil {
+		resolver = net.JoinHostPort(resolver, "53")
+	}
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return &MXVerifier{
+		resolver: resolver,
+		timeout:  timeout,
+		sem:      make(chan struct{}, concurrency),
+		cache:    make(map[string]*mxLookup),
+	}
+}
+
+// SystemResolver returns the first nameserver of /etc/resolv.conf, or the
+// local one like the go resolver does without any.
+func SystemResolver() string {
+	file, err := os.Open("/etc/resolv.conf")
+	if err != nil {
+		return "127.0.0.1:53"
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			return net.JoinHostPort(fields[1], "53")
+		}
+	}
+	return "127.0.0.1:53"
+}
+
+// Verify returns the MX status of domain, looking it up on the first call.
+// It fails with ctx.Err() once ctx is done, and a lookup stopped by ctx is
+// not cached.
+func (v *MXVerifier) Verify(ctx context.Context, domain string) (string, error) {
+	domain = strings.ToLower(domain)
+	v.mu.Lock()
+	l, ok := v.cache[domain]
+	if !ok {
+		l = &mxLookup{done: make(chan struct{})}
+		v.cache[domain] = l
+	}
+	v.mu.Unlock()
+
+	if !ok {
+		select {
+		case v.sem <- struct{}{}:
+			l.status, l.err = v.lookup(ctx, domain)
+			<-v.sem
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			l.status, l.err = "", ctx.Err()
+			v.mu.Lock()
+			delete(v.cache, domain)
+			v.mu.Unlock()
+		}
+		close(l.done)
+	}
+	select {
+	case <-l.done:
+		return l.status, l.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// Status returns the status of domain if it was verified.
+func (v *MXVerifier) Status(domain string) string {
+	v.mu.Lock()
+	l, ok := v.cache[strings.ToLower(domain)]
+	v.mu.Unlock()
+	if !ok {
+		return ""
+	}
+	select {
+	case <-l.done:
+		return l.status
+	default:
+		return ""
+	}
+}
+
+func (v *MXVerifier) lookup(ctx context.Context, domain string) (string, error) {
+	ascii, err := idna.Lookup.ToASCII(domain)
+	if err != nil {
+		return "", err
+	}
+	name, err := dnsmessage.NewName(strings.TrimSuffix(ascii, ".") + ".")
+	if err != nil {
+		return "", err
+	}
+
+	rcode, answers, err := v.query(ctx, name, dnsmessage.TypeMX)
+	if err != nil {
+		return "", err
+	}
+	if rcode == dnsmessage.RCodeNameError {
+		return MXNXDomain, nil
+	}
+	for _, a := range answers {
+		mx, ok := a.Body.(*dnsmessage.MXResource)
+		if !ok {
+			continue
+		}
+		// a null MX of RFC 7505, the domain takes no mail
+		if mx.MX.String() == "." {
+			return MXNone, nil
+		}
+		return MXOK, nil
+	}
+
+	// without MX records, mail goes to the address of the domain
+	for _, t := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		_, answers, err := v.query(ctx, name, t)
+		if err != nil {
+			return "", err
+		}
+		for _, a := range answers {
+			if a.Header.Type == t {
+				return MXOK, nil
+			}
+		}
+	}
+	return MXNone, nil
+}
+
+// query sends a recursive query of type t for name to the resolver over
+// udp, and returns the response code and answers, unless ctx is done first.
+func (v *MXVerifier) query(ctx context.Context, name dnsmessage.Name, t dnsmessage.Type) (dnsmessage.RCode, []dnsmessage.Resource, error) {
+	var idBytes [2]byte
+	if _, err := rand.Read(idBytes[:]); err != nil {
+		return 0, nil, err
+	}
+	id := binary.BigEndian.Uint16(idBytes[:])
+
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, RecursionDesired: true})
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return 0, nil, err
+	}
+	if err := b.Question(dnsmessage.Question{Name: name, Type: t, Class: dnsmessage.ClassINET}); err != nil {
+		return 0, nil, err
+	}
+	// EDNS0, so large answers are not truncated
+	if err := b.StartAdditionals(); err != nil {
+		return 0, nil, err
+	}
+	var opt dnsmessage.ResourceHeader
+	if err := opt.SetEDNS0(4096, dnsmessage.RCodeSuccess, false); err != nil {
+		return 0, nil, err
+	}
+	if err := b.OPTResource(opt, dnsmessage.OPTResource{}); err != nil {
+		return 0, nil, err
+	}
+	query, err := b.Finish()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	conn, err := (&net.Dialer{Timeout: v.timeout}).DialContext(ctx, "udp", v.resolver)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(v.timeout)); err != nil {
+		return 0, nil, err
+	}
+	// ctx done stops the query too, once ctx.Err() tells why
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	defer stop()
+	if _, err := conn.Write(query); err != nil {
+		return 0, nil, err
+	}
+
+	buf := make([]byte, 4096)
+	for {
+		n, err := conn.Read(buf)
+		if ctx.Err() != nil {
+			return 0, nil, ctx.Err()
+		}
+		if err != nil {
+			return 0, nil, fmt.Errorf("querying %s for %s: %w", v.resolver, name, err)
+		}
+		var msg dnsmessage.Message
+		// skip stray responses, to other queries
+		if err := msg.Unpack(buf[:n]); err != nil || msg.ID != id || !msg.Response {
+			continue
+		}
+		if msg.Truncated {
+			return 0, nil, fmt.Errorf("querying %s for %s: truncated response", v.resolver, name)
+		}
+		if msg.RCode != dnsmessage.RCodeSuccess && msg.RCode != dnsmessage.RCodeNameError {
+			return 0, nil, fmt.Errorf("querying %s for %s: %s", v.resolver, name, msg.RCode)
+		}
+		return msg.RCode, msg.Answers, nil
+	}
+}
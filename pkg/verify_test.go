@@ -0,0 +1,235 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// stubDNS answers queries for a few test domains, NXDOMAIN for others, and
+// counts the queries per name and type.
+type stubDNS struct {
+	conn    net.PacketConn
+	mu      sync.Mutex
+	queries map[dnsmessage.Question]int
+}
+
+func newStubDNS(t *testing.T) *stubDNS {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &stubDNS{conn: conn, queries: map[dnsmessage.Question]int{}}
+	t.Cleanup(func() { conn.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *stubDNS) addr() string {
+	return s.conn.LocalAddr().String()
+}
+
+func (s *stubDNS) count(name string, t dnsmessage.Type) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.queries[dnsmessage.Question{Name: dnsmessage.MustNewName(name), Type: t, Class: dnsmessage.ClassINET}]
+}
+
+func (s *stubDNS) serve() {
+	buf := make([]byte, 4096)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		var query dnsmessage.Message
+		if err := query.Unpack(buf[:n]); err != nil || len(query.Questions) != 1 {
+			continue
+		}
+		q := query.Questions[0]
+		s.mu.Lock()
+		s.queries[q]++
+		s.mu.Unlock()
+
+		header := dnsmessage.ResourceHeader{Name: q.Name, Type: q.Type, Class: dnsmessage.ClassINET, TTL: 60}
+		response := dnsmessage.Message{
+			Header:    dnsmessage.Header{ID: query.ID, Response: true, RecursionAvailable: true},
+			Questions: query.Questions,
+		}
+		switch name := q.Name.String(); {
+		case name == "example.com." && q.Type == dnsmessage.TypeMX:
+			response.Answers = []dnsmessage.Resource{{Header: header, Body: &dnsmessage.MXResource{Pref: 10, MX: dnsmessage.MustNewName("mx.example.com.")}}}
+		case name == "web.example." && q.Type == dnsmessage.TypeA:
+			response.Answers = []dnsmessage.Resource{{Header: header, Body: &dnsmessage.AResource{A: [4]byte{127, 0, 0, 1}}}}
+		case name == "nullmx.example." && q.Type == dnsmessage.TypeMX:
+			response.Answers = []dnsmessage.Resource{{Header: header, Body: &dnsmessage.MXResource{MX: dnsmessage.MustNewName(".")}}}
+		case name == "web.example." || name == "nullmx.example." || name == "nodata.example.":
+		case name == "example.com.":
+		default:
+			response.RCode = dnsmessage.RCodeNameError
+		}
+		b, err := response.Pack()
+		if err != nil {
+			continue
+		}
+		_, _ = s.conn.WriteTo(b, addr)
+	}
+}
+
+func TestMXVerifier(t *testing.T) {
+	dns := newStubDNS(t)
+	v := NewMXVerifier(dns.addr(), 2, 0)
+
+	tests := []struct {
+		domain   string
+		expected string
+	}{
+		{"example.com", MXOK},
+		{"web.example", MXOK},
+		{"nullmx.example", MXNone},
+		{"nodata.example", MXNone},
+		{"gone.example", MXNXDomain},
+	}
+	for _, test := range tests {
+		t.Run(test.domain, func(t *testing.T) {
+			// concurrent lookups of the same domain share one query
+			var wg sync.WaitGroup
+			for i := 0; i < 5; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					status, err := v.Verify(context.Background(), test.domain)
+					if err != nil {
+						t.Error(err)
+					}
+					if status != test.expected {
+						t.Errorf("Verify(%q) = %q, want %q", test.domain, status, test.expected)
+					}
+				}()
+			}
+			wg.Wait()
+			if n := dns.count(test.domain+".", dnsmessage.TypeMX); n != 1 {
+				t.Errorf("%d MX queries for %s, want 1", n, test.domain)
+			}
+			if got := v.Status(test.domain); got != test.expected {
+				t.Errorf("Status(%q) = %q, want %q", test.domain, got, test.expected)
+			}
+		})
+	}
+}
+
+// heldDNS answers NXDOMAIN to every query once release is closed, or after
+// a second, counting the queries answered late.
+func heldDNS(t *testing.T, release chan struct{}) (string, *atomic.Int32) {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	late := &atomic.Int32{}
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var query dnsmessage.Message
+			if err := query.Unpack(buf[:n]); err != nil {
+				continue
+			}
+			go func() {
+				select {
+				case <-release:
+				case <-time.After(time.Second):
+					late.Add(1)
+				}
+				response := dnsmessage.Message{
+					Header:    dnsmessage.Header{ID: query.ID, Response: true, RCode: dnsmessage.RCodeNameError},
+					Questions: query.Questions,
+				}
+				if b, err := response.Pack(); err == nil {
+					_, _ = conn.WriteTo(b, addr)
+				}
+			}()
+		}
+	}()
+	return conn.LocalAddr().String(), late
+}
+
+func TestVerifyCancelled(t *testing.T) {
+	release := make(chan struct{})
+	addr, _ := heldDNS(t, release)
+	v := NewMXVerifier(addr, 1, 5*time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := v.Verify(ctx, "example.com"); !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > time.Second {
+		t.Errorf("Verify() = %v after %v, want stopped by ctx", err, time.Since(start))
+	}
+	// not cached, looked up again
+	close(release)
+	if status, err := v.Verify(context.Background(), "example.com"); status != MXNXDomain || err != nil {
+		t.Errorf("Verify() = %q, %v, want %q once looked up again", status, err, MXNXDomain)
+	}
+}
+
+func TestCrawlVerifyReleasesHost(t *testing.T) {
+	release := make(chan struct{})
+	addr, late := heldDNS(t, release)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the lookups of /1 wait for /2 to be requested
+		if r.URL.Path == "/2" {
+			close(release)
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<html><body>team@page%s.org</body></html>", r.URL.Path[1:])
+	}))
+	t.Cleanup(ts.Close)
+
+	hc := newTestHTTPChallenge(ts, "", false)
+	hc.options.IgnoreRobots = true
+	// one request at a time to the host
+	hc.scheduler = NewHostScheduler(0, 1, 0)
+	hc.verifier = NewMXVerifier(addr, 2, 5*time.Second)
+	hc.CrawlURLsWithWorkerPool(context.Background(), []string{ts.URL + "/1", ts.URL + "/2"})
+
+	if hc.TotalURLsCrawled != 2 || late.Load() != 0 {
+		t.Errorf("TotalURLsCrawled = %d, %d lookups answered late, want /2 requested while /1 is verified", hc.TotalURLsCrawled, late.Load())
+	}
+}
+
+func TestCrawlVerify(t *testing.T) {
+	dns := newStubDNS(t)
+	ts := newTestSite(t)
+
+	hc := newTestHTTPChallenge(ts, "", false)
+	hc.verifier = NewMXVerifier(dns.addr(), 4, 0)
+	records := &recordSink{}
+	hc.AddSink(records)
+	hc.CrawlRecursiveParallel(context.Background(), ts.URL+"/page/0")
+
+	if counts := hc.MXCounts(); counts[MXOK] != testSitePages || len(counts) != 1 {
+		t.Errorf("MXCounts() = %v, want %d %s", counts, testSitePages, MXOK)
+	}
+	for _, r := range records.Records() {
+		if r.MX != MXOK {
+			t.Errorf("record %s MX = %q, want %q", r.Value, r.MX, MXOK)
+		}
+	}
+	if n := dns.count("example.com.", dnsmessage.TypeMX); n != 1 {
+		t.Errorf("%d MX queries for example.com, want 1", n)
+	}
+}